@@ -2,23 +2,42 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
-	_ "github.com/asim/go-micro/plugins/broker/nats/v3"
+	"github.com/asim/go-micro/v3"
 	"github.com/asim/go-micro/v3/server"
 
 	"github.com/Mikhalevich/filesharing/internal/handler"
+	"github.com/Mikhalevich/filesharing/internal/mail"
+	"github.com/Mikhalevich/filesharing/internal/preview"
+	"github.com/Mikhalevich/filesharing/internal/ratelimit"
 	"github.com/Mikhalevich/filesharing/internal/router"
+	"github.com/Mikhalevich/filesharing/internal/search"
+	"github.com/Mikhalevich/filesharing/internal/storages"
+	"github.com/Mikhalevich/filesharing/pkg/password"
 	"github.com/Mikhalevich/filesharing/pkg/service"
 )
 
 type config struct {
 	service.Config `yaml:"service"`
+	RateLimit      ratelimit.Config `yaml:"rate_limit"`
+	PasswordPolicy password.Policy  `yaml:"password_policy"`
+	Mail           mail.Config      `yaml:"mail"`
+	Search         search.Config    `yaml:"search"`
+	Preview        preview.Config   `yaml:"preview"`
+	StorageCache   storages.Config  `yaml:"storage_cache"`
+	Handler        handler.Config   `yaml:",inline"`
 }
 
 func (c *config) Service() service.Config {
 	return c.Config
 }
 
+func (c *config) Reloadable() []string {
+	return []string{"rate_limit", "service.shutdown_timeout", "service.log"}
+}
+
 func (c *config) Validate() error {
 	if c.Config.FileServiceName == "" {
 		return errors.New("file_service_name is required")
@@ -28,14 +47,107 @@ func (c *config) Validate() error {
 		return errors.New("auth_service_name is required")
 	}
 
+	if err := c.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+
+	if err := c.PasswordPolicy.Validate(); err != nil {
+		return fmt.Errorf("password_policy: %w", err)
+	}
+
+	if err := c.Mail.Validate(); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	if err := c.Search.Validate(); err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+
+	if err := c.Preview.Validate(); err != nil {
+		return fmt.Errorf("preview: %w", err)
+	}
+
+	if err := c.StorageCache.Validate(); err != nil {
+		return fmt.Errorf("storage_cache: %w", err)
+	}
+
+	if err := c.Handler.Validate(); err != nil {
+		return fmt.Errorf("handler: %w", err)
+	}
+
 	return nil
 }
 
 func main() {
-	var cfg config
+	cfg := config{
+		PasswordPolicy: password.DefaultPolicy(),
+		Mail:           mail.DefaultConfig(),
+		Search:         search.DefaultConfig(),
+		Preview:        preview.DefaultConfig(),
+		StorageCache:   storages.DefaultConfig(),
+		Handler:        handler.DefaultConfig(),
+	}
 	service.Run("filesharig", &cfg, func(srv server.Server, s service.Servicer) error {
 		filePub := s.Publisher().New("filesharing.file.event")
-		h := handler.NewHandler(s.ClientManager().Auth(), s.ClientManager().File(), s.Logger(), filePub)
+		accountPub := s.Publisher().New("filesharing.account.event")
+		storagePub := s.Publisher().New("filesharing.storage.event")
+		limiter := ratelimit.New(cfg.RateLimit, ratelimit.NewMemoryStore())
+		s.AddOption(service.WithReloadAction(func() {
+			limiter.SetConfig(cfg.RateLimit)
+		}))
+
+		sender, err := mail.NewSender(cfg.Mail)
+		if err != nil {
+			return fmt.Errorf("create mail sender: %w", err)
+		}
+		mailer := mail.NewMailer(sender, cfg.Mail.From, cfg.Mail.ResetURL)
+
+		index := search.NewIndex(cfg.Search.MaxTerms)
+		if cfg.Search.Snapshot != "" {
+			if err := index.Load(cfg.Search.Snapshot); err != nil {
+				return fmt.Errorf("load search index: %w", err)
+			}
+
+			s.AddOption(service.WithPostAction(func() {
+				if err := index.Save(cfg.Search.Snapshot); err != nil {
+					s.Logger().WithError(err).Error("unable to save search index")
+				}
+			}))
+		}
+
+		indexer := search.NewIndexer(index, s.ClientManager().File(), s.Logger(), cfg.Search.MaxFileSize, time.Duration(cfg.Search.IndexWait)*time.Second)
+		if err := micro.RegisterSubscriber("filesharing.file.event", srv, indexer.HandleFileEvent); err != nil {
+			return fmt.Errorf("register file event subscriber: %w", err)
+		}
+
+		if err := micro.RegisterSubscriber("filesharing.account.event", srv, indexer.HandleAccountEvent); err != nil {
+			return fmt.Errorf("register account event subscriber: %w", err)
+		}
+
+		generator := preview.NewGenerator(preview.NewDirCache(cfg.Preview.Directory), s.ClientManager().File(), s.Logger(), cfg.Preview)
+		if err := micro.RegisterSubscriber("filesharing.file.event", srv, generator.HandleFileEvent); err != nil {
+			return fmt.Errorf("register file event subscriber: %w", err)
+		}
+
+		if err := micro.RegisterSubscriber("filesharing.account.event", srv, generator.HandleAccountEvent); err != nil {
+			return fmt.Errorf("register account event subscriber: %w", err)
+		}
+
+		storageCache := storages.NewCache(cfg.StorageCache)
+		if err := micro.RegisterSubscriber("filesharing.storage.event", srv, storageCache.HandleStorageEvent); err != nil {
+			return fmt.Errorf("register storage event subscriber: %w", err)
+		}
+
+		if err := micro.RegisterSubscriber("filesharing.account.event", srv, storageCache.HandleAccountEvent); err != nil {
+			return fmt.Errorf("register account event subscriber: %w", err)
+		}
+
+		var history handler.Historier
+		if c := s.ClientManager().History(); c != nil {
+			history = c
+		}
+
+		h := handler.NewHandler(s.ClientManager().Auth(), s.ClientManager().File(), history, indexer, generator, s.Logger(), filePub, accountPub, storagePub, storageCache, limiter, cfg.PasswordPolicy, mailer, cfg.Handler)
 
 		router.MakeRoutes(s.Router(), true, h, s.Logger())
 
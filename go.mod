@@ -5,11 +5,18 @@ go 1.16
 require (
 	github.com/Mikhalevich/filesharing-auth-service v0.0.0-20220212204429-a7aef22026ad
 	github.com/asim/go-micro/plugins/broker/nats/v3 v3.0.0-20210913205636-4c7d2e28eb3b
+	github.com/asim/go-micro/plugins/registry/etcd/v3 v3.7.0
 	github.com/asim/go-micro/v3 v3.6.0
+	github.com/fsnotify/fsnotify v1.5.1
+	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/gorilla/mux v1.8.0
-	github.com/prometheus/client_golang v1.1.0
+	github.com/micro/cli/v2 v2.1.2
+	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/sirupsen/logrus v1.8.1
 	go.elastic.co/ecslogrus v1.0.0
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
+	golang.org/x/text v0.3.7
 	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/Mikhalevich/filesharing/internal/names"
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+)
+
+func archiveFileName(dir string, name string) string {
+	return path.Join("files", dir, path.Clean("/" + name)[1:])
+}
+
+// ExportAccountHandler streams zip archive with all storage files, their older versions, metadata and history
+// trashed files are listed in metadata only, file service doesn't serve their content
+func (h *Handler) ExportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "ExportAccountHandler")
+		return
+	}
+
+	if sp.StorageName == "" {
+		h.Error(httperror.NewInvalidParams("invalid storage name"), w, r, "ExportAccountHandler")
+		return
+	}
+
+	temporary, err := h.file.Files(sp.StorageName, false)
+	if err != nil {
+		h.Error(httperror.NewInternalError("unable to get temporary files").WithError(err), w, r, "ExportAccountHandler")
+		return
+	}
+
+	permanent, err := h.file.Files(sp.StorageName, true)
+	if err != nil {
+		h.Error(httperror.NewInternalError("unable to get permanent files").WithError(err), w, r, "ExportAccountHandler")
+		return
+	}
+
+	trash := make(map[bool][]*file.File, 2)
+	for _, isPermanent := range []bool{false, true} {
+		trash[isPermanent], err = h.file.TrashFiles(sp.StorageName, isPermanent)
+		if err != nil {
+			h.Error(httperror.NewInternalError("unable to get trash files").WithError(err), w, r, "ExportAccountHandler")
+			return
+		}
+	}
+
+	versions := make(map[string][]*file.File, len(permanent))
+	for _, f := range permanent {
+		vs, err := h.file.Versions(sp.StorageName, f.GetName())
+		if err != nil && errorCode(err) != httperror.CodeNotExist {
+			h.Error(httperror.NewInternalError(fmt.Sprintf("unable to get versions for file: %s", f.GetName())).WithError(err), w, r, "ExportAccountHandler")
+			return
+		}
+		versions[f.GetName()] = vs
+	}
+
+	var events []*event.FileEvent
+	if h.history != nil && sp.UserID != 0 {
+		events, err = h.history.List(sp.UserID)
+		if err != nil {
+			h.Error(httperror.NewInternalError("unable to get history").WithError(err), w, r, "ExportAccountHandler")
+			return
+		}
+	}
+
+	type JSONFile struct {
+		Name      string `json:"name"`
+		Size      int64  `json:"size"`
+		ModTime   int64  `json:"mod_time"`
+		Permanent bool   `json:"permanent"`
+		Version   int64  `json:"version,omitempty"`
+		Path      string `json:"path"`
+	}
+
+	type JSONTrashFile struct {
+		Name      string `json:"name"`
+		Size      int64  `json:"size"`
+		ModTime   int64  `json:"mod_time"`
+		DeletedAt int64  `json:"deleted_at"`
+		Permanent bool   `json:"permanent"`
+	}
+
+	type JSONMetadata struct {
+		Storage    string          `json:"storage"`
+		UserID     int64           `json:"user_id"`
+		ExportedAt int64           `json:"exported_at"`
+		Files      []JSONFile      `json:"files"`
+		Versions   []JSONFile      `json:"versions"`
+		Trash      []JSONTrashFile `json:"trash"`
+		Excluded   []string        `json:"excluded"`
+	}
+
+	metadata := JSONMetadata{
+		Storage:    sp.StorageName,
+		UserID:     sp.UserID,
+		ExportedAt: time.Now().Unix(),
+		Files:      make([]JSONFile, 0, len(temporary)+len(permanent)),
+		Versions:   []JSONFile{},
+		Trash:      make([]JSONTrashFile, 0, len(trash[false])+len(trash[true])),
+		Excluded:   []string{"content of trashed files, restore them before export to get it"},
+	}
+
+	// version is set for older versions of permanent files only
+	type archiveEntry struct {
+		file      *file.File
+		permanent bool
+		version   int64
+		path      string
+	}
+
+	entries := make([]archiveEntry, 0, len(temporary)+len(permanent))
+	for _, f := range temporary {
+		entries = append(entries, archiveEntry{file: f, permanent: false, path: archiveFileName("temporary", f.GetName())})
+	}
+	for _, f := range permanent {
+		entries = append(entries, archiveEntry{file: f, permanent: true, path: archiveFileName("permanent", f.GetName())})
+	}
+	for _, f := range permanent {
+		for _, v := range versions[f.GetName()] {
+			if v.GetVersion() == 0 || v.GetVersion() == f.GetVersion() {
+				continue
+			}
+
+			entries = append(entries, archiveEntry{
+				file:      v,
+				permanent: true,
+				version:   v.GetVersion(),
+				path:      path.Join(archiveFileName("versions", f.GetName()), strconv.FormatInt(v.GetVersion(), 10)),
+			})
+		}
+	}
+
+	for _, e := range entries {
+		jf := JSONFile{
+			Name:      e.file.GetName(),
+			Size:      e.file.GetSize(),
+			ModTime:   e.file.GetModTime(),
+			Permanent: e.permanent,
+			Version:   e.version,
+			Path:      e.path,
+		}
+
+		if e.version != 0 {
+			metadata.Versions = append(metadata.Versions, jf)
+		} else {
+			metadata.Files = append(metadata.Files, jf)
+		}
+	}
+
+	for _, isPermanent := range []bool{false, true} {
+		for _, f := range trash[isPermanent] {
+			metadata.Trash = append(metadata.Trash, JSONTrashFile{
+				Name:      f.GetName(),
+				Size:      f.GetSize(),
+				ModTime:   f.GetModTime(),
+				DeletedAt: f.GetDeletedAt(),
+				Permanent: isPermanent,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", names.ContentDisposition("attachment", "export.zip"))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// response status is already sent, so errors below are only logged
+	logger := h.logger.WithField("handler", "ExportAccountHandler").WithField("storage", sp.StorageName)
+
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     e.path,
+			Method:   zip.Deflate,
+			Modified: time.Unix(e.file.GetModTime(), 0),
+		})
+		if err != nil {
+			logger.WithError(err).Error("unable to create archive entry")
+			return
+		}
+
+		if e.version != 0 {
+			err = h.file.GetVersion(sp.StorageName, e.file.GetName(), e.version, fw)
+		} else {
+			err = h.file.Get(sp.StorageName, e.permanent, e.file.GetName(), fw)
+		}
+
+		if err != nil {
+			logger.WithError(err).WithField("file", e.file.GetName()).Error("unable to export file")
+			return
+		}
+	}
+
+	if err := writeJSONEntry(zw, "metadata.json", metadata); err != nil {
+		logger.WithError(err).Error("unable to write metadata")
+		return
+	}
+
+	if events == nil {
+		events = []*event.FileEvent{}
+	}
+
+	if err := writeJSONEntry(zw, "history.json", events); err != nil {
+		logger.WithError(err).Error("unable to write history")
+		return
+	}
+
+	if err := zw.Close(); err != nil {
+		logger.WithError(err).Error("unable to close archive")
+		return
+	}
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, v interface{}) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("create entry: %w", err)
+	}
+
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAccountHandler removes storage(user) with all files and history
+// other services are notified with account event to clean up their data
+func (h *Handler) DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "DeleteAccountHandler")
+		return
+	}
+
+	if sp.IsPublic {
+		h.Error(httperror.NewInvalidParams("public storage can't be deleted"), w, r, "DeleteAccountHandler")
+		return
+	}
+
+	password := r.FormValue("password")
+	if password == "" {
+		h.Error(httperror.NewInvalidParams("invalid password"), w, r, "DeleteAccountHandler")
+		return
+	}
+
+	if !h.allowRequest(w, r, sp.StorageName, "DeleteAccountHandler") {
+		return
+	}
+
+	user := &auth.User{
+		Id:       sp.UserID,
+		Name:     sp.StorageName,
+		Password: password,
+	}
+
+	// password is checked before storage removal, user is deleted the last
+	// so failed request can be retried with the same password
+	_, _, err = h.auth.Auth(user)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError("no such storage"), w, r, "DeleteAccountHandler")
+
+		case httperror.CodeNotMatch:
+			locked, err := h.limiter.Fail(h.limiter.ClientIP(r), sp.StorageName)
+			if err != nil {
+				h.Error(httperror.NewInternalError("rate limit error").WithError(err), w, r, "DeleteAccountHandler")
+				return
+			}
+
+			if locked > 0 {
+				h.tooManyRequests(locked, w, r, "DeleteAccountHandler")
+				return
+			}
+
+			h.Error(httperror.NewNotMatchError("password not match"), w, r, "DeleteAccountHandler")
+
+		default:
+			h.Error(httperror.NewInternalError("check password error").WithError(err), w, r, "DeleteAccountHandler")
+		}
+		return
+	}
+
+	if err := h.file.RemoveStorage(sp.StorageName); err != nil && errorCode(err) != httperror.CodeNotExist {
+		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to remove storage: %s", sp.StorageName)).WithError(err), w, r, "DeleteAccountHandler")
+		return
+	}
+
+	h.storages.Remove(sp.StorageName)
+	h.publishStorageEvent(sp.StorageName, event.StorageAction_StorageDeleted)
+
+	if err := h.auth.Delete(user); err != nil {
+		h.Error(httperror.NewInternalError("delete user error").WithError(err), w, r, "DeleteAccountHandler")
+		return
+	}
+
+	go func() {
+		if err := h.accountPub.Publish(context.Background(), &event.AccountEvent{
+			UserID:   sp.UserID,
+			UserName: sp.StorageName,
+			Time:     time.Now().Unix(),
+			Action:   event.AccountAction_Deleted,
+		}); err != nil {
+			h.logger.WithError(err).WithField("storage", sp.StorageName).Error("unable to publish account event")
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Mikhalevich/filesharing/internal/names"
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+)
+
+// AdminMiddleware middleware checks admin token, admin routes are unavailable without configured token
+func (h *Handler) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.cfg.Storages.AdminToken == "" {
+			h.Error(httperror.NewUnauthorized("admin routes are disabled"), w, r, "AdminMiddleware")
+			return
+		}
+
+		token := extractToken(r)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.Storages.AdminToken)) != 1 {
+			h.Error(httperror.NewUnauthorized("invalid admin token"), w, r, "AdminMiddleware")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// orphanedStorages returns storages without registered or anonymous public user
+func (h *Handler) orphanedStorages() ([]string, error) {
+	storages, err := h.file.Storages()
+	if err != nil {
+		return nil, fmt.Errorf("list storages: %w", err)
+	}
+
+	if len(storages) == 0 {
+		return []string{}, nil
+	}
+
+	users, err := h.auth.ExistingUsers(storages)
+	if err != nil {
+		return nil, fmt.Errorf("existing users: %w", err)
+	}
+
+	registered := make(map[string]bool, len(users))
+	for _, u := range users {
+		registered[u] = true
+	}
+
+	orphaned := make([]string, 0, len(storages)-len(registered))
+	for _, s := range storages {
+		if !registered[s] {
+			orphaned = append(orphaned, s)
+		}
+	}
+
+	return orphaned, nil
+}
+
+// OrphanedStoragesHandler returns json encoded list of storages without user
+func (h *Handler) OrphanedStoragesHandler(w http.ResponseWriter, r *http.Request) {
+	orphaned, err := h.orphanedStorages()
+	if err != nil {
+		h.Error(httperror.NewInternalError("unable to get orphaned storages").WithError(err), w, r, "OrphanedStoragesHandler")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(orphaned); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "OrphanedStoragesHandler")
+		return
+	}
+}
+
+// RemoveOrphanedStorageHandler removes storage without registered or anonymous public user
+// owner is checked by file service together with removal, so storage registered meanwhile is kept
+func (h *Handler) RemoveOrphanedStorageHandler(w http.ResponseWriter, r *http.Request) {
+	name, err := names.NormalizeStorage(r.FormValue("name"))
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid storage name").WithError(err), w, r, "RemoveOrphanedStorageHandler")
+		return
+	}
+
+	if err := h.file.RemoveUnownedStorage(name); err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError(fmt.Sprintf("storage %s doesn't exist", name)), w, r, "RemoveOrphanedStorageHandler")
+		case httperror.CodeAlreadyExist:
+			h.Error(httperror.NewAlreadyExistError(fmt.Sprintf("storage %s belongs to user", name)), w, r, "RemoveOrphanedStorageHandler")
+		default:
+			h.Error(httperror.NewInternalError(fmt.Sprintf("unable to remove storage: %s", name)).WithError(err), w, r, "RemoveOrphanedStorageHandler")
+		}
+		return
+	}
+
+	h.storages.Remove(name)
+	h.publishStorageEvent(name, event.StorageAction_StorageDeleted)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Config gateway handler configuration
+type Config struct {
+	MFAIssuer string         `yaml:"mfa_issuer"`
+	TTL       TTLConfig      `yaml:"ttl"`
+	Versions  VersionsConfig `yaml:"versions"`
+	List      ListConfig     `yaml:"list"`
+	Upload    UploadConfig   `yaml:"upload"`
+	Storages  StoragesConfig `yaml:"storages"`
+}
+
+// TTLConfig lifetime of files in temporary storage in seconds
+// zero ttl keeps file until temporary storage clean time
+type TTLConfig struct {
+	Default  int            `yaml:"default"`
+	Max      int            `yaml:"max"`
+	Storages map[string]int `yaml:"storages"`
+}
+
+// VersionsConfig default prune policy for file versions in permanent storage
+// keep is amount of versions to keep, at least one, max_age is in seconds, zero disables age rule
+type VersionsConfig struct {
+	Keep   int `yaml:"keep"`
+	MaxAge int `yaml:"max_age"`
+}
+
+// ListConfig page sizes for paginated file list
+type ListConfig struct {
+	DefaultPageSize int `yaml:"default_page_size"`
+	MaxPageSize     int `yaml:"max_page_size"`
+}
+
+// UploadConfig upload limits in bytes, zero disables limit
+// concurrency is amount of files from one request uploaded in parallel
+type UploadConfig struct {
+	MaxRequestSize int64 `yaml:"max_request_size"`
+	MaxFileSize    int64 `yaml:"max_file_size"`
+	Concurrency    int   `yaml:"concurrency"`
+}
+
+// StoragesConfig storage lifecycle
+// public enables anonymous storages without password, they are created on the first upload
+// it's enabled by default to keep existing public storages available, admin routes are disabled if admin_token is empty
+type StoragesConfig struct {
+	Public     bool   `yaml:"public"`
+	AdminToken string `yaml:"admin_token"`
+}
+
+// DefaultConfig used for omitted configuration values
+func DefaultConfig() Config {
+	return Config{
+		MFAIssuer: "filesharing",
+		Versions: VersionsConfig{
+			Keep: 10,
+		},
+		List: ListConfig{
+			DefaultPageSize: 100,
+			MaxPageSize:     1000,
+		},
+		Upload: UploadConfig{
+			MaxRequestSize: 1 << 30,
+			MaxFileSize:    1 << 30,
+			Concurrency:    1,
+		},
+		Storages: StoragesConfig{
+			Public: true,
+		},
+	}
+}
+
+func (c Config) Validate() error {
+	if c.MFAIssuer == "" {
+		return fmt.Errorf("mfa_issuer is required")
+	}
+
+	if err := c.TTL.Validate(); err != nil {
+		return fmt.Errorf("ttl: %w", err)
+	}
+
+	if c.Versions.Keep < 1 || c.Versions.MaxAge < 0 {
+		return fmt.Errorf("versions: invalid prune policy keep = %d max_age = %d", c.Versions.Keep, c.Versions.MaxAge)
+	}
+
+	if c.List.DefaultPageSize <= 0 || c.List.MaxPageSize < c.List.DefaultPageSize {
+		return fmt.Errorf("list: invalid page size default = %d max = %d", c.List.DefaultPageSize, c.List.MaxPageSize)
+	}
+
+	if c.Upload.MaxRequestSize < 0 || c.Upload.MaxFileSize < 0 {
+		return fmt.Errorf("upload: invalid size limit request = %d file = %d", c.Upload.MaxRequestSize, c.Upload.MaxFileSize)
+	}
+
+	if c.Upload.Concurrency < 1 {
+		return fmt.Errorf("upload: invalid concurrency: %d", c.Upload.Concurrency)
+	}
+
+	return nil
+}
+
+func (c TTLConfig) Validate() error {
+	if c.Default < 0 {
+		return fmt.Errorf("invalid default: %d", c.Default)
+	}
+
+	if c.Max < 0 {
+		return fmt.Errorf("invalid max: %d", c.Max)
+	}
+
+	if c.Max > 0 && c.Default > c.Max {
+		return fmt.Errorf("default %d is greater than max %d", c.Default, c.Max)
+	}
+
+	for name, ttl := range c.Storages {
+		if ttl < 0 || (c.Max > 0 && ttl > c.Max) {
+			return fmt.Errorf("invalid ttl %d for storage %s", ttl, name)
+		}
+	}
+
+	return nil
+}
+
+// fileTTL returns ttl for uploaded file
+// requested value is either amount of seconds or duration string like 90m
+func (c TTLConfig) fileTTL(storage string, requested string) (time.Duration, error) {
+	if requested == "" {
+		if ttl, ok := c.Storages[storage]; ok {
+			return time.Duration(ttl) * time.Second, nil
+		}
+		return time.Duration(c.Default) * time.Second, nil
+	}
+
+	var ttl time.Duration
+	if seconds, err := strconv.ParseInt(requested, 10, 64); err == nil {
+		ttl = time.Duration(seconds) * time.Second
+	} else {
+		d, err := time.ParseDuration(requested)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl: %s", requested)
+		}
+		ttl = d
+	}
+
+	if ttl < time.Second {
+		return 0, fmt.Errorf("ttl should be at least one second: %s", requested)
+	}
+
+	if c.Max > 0 && ttl > time.Duration(c.Max)*time.Second {
+		return 0, fmt.Errorf("ttl %s exceeds max %d seconds", requested, c.Max)
+	}
+
+	return ttl, nil
+}
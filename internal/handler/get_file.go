@@ -1,32 +1,78 @@
 package handler
 
 import (
-	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/Mikhalevich/filesharing/internal/names"
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/mimetype"
 )
 
+// sandboxPolicy disallows scripts, plugins and same origin access for active content viewed inline
+const sandboxPolicy = "sandbox; default-src 'none'; img-src 'self' data:; style-src 'unsafe-inline'; media-src 'self'"
+
+// typedPipeWriter keeps stored content type sent by file service before content
+type typedPipeWriter struct {
+	*io.PipeWriter
+	contentType string
+}
+
+func (w *typedPipeWriter) SetContentType(contentType string) {
+	w.contentType = contentType
+}
+
+// writeFile streams file content fetched by get into response
+// stored content type is used if file service sends it, otherwise it's sniffed from the stream
+// with inline=1 file is shown by browser instead of download
+func writeFile(w http.ResponseWriter, r *http.Request, fileName string, get func(w io.Writer) error) error {
+	pr, pipeWriter := io.Pipe()
+	pw := &typedPipeWriter{PipeWriter: pipeWriter}
+	go func() {
+		err := get(pw)
+		pw.CloseWithError(err)
+	}()
+
+	// content type is set before content is written into pipe, so it's known once content is peeked
+	contentType, body, err := mimetype.DetectReader(fileName, pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+
+	if pw.contentType != "" {
+		contentType = pw.contentType
+	}
+
+	disposition := "attachment"
+	if isSet(r.FormValue("inline")) {
+		disposition = "inline"
+		if mimetype.IsActive(contentType) {
+			w.Header().Set("Content-Security-Policy", sandboxPolicy)
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", names.ContentDisposition(disposition, fileName))
+
+	_, err = io.Copy(w, body)
+	return err
+}
+
 // GetFileHandler get single file from storage
 func (h *Handler) GetFileHandler(w http.ResponseWriter, r *http.Request) {
 	sp, err := h.requestParameters(r)
 	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "GetFileHandler")
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "GetFileHandler")
 		return
 	}
 
-	pr, pw := io.Pipe()
-	go func() {
-		err := h.file.Get(sp.StorageName, sp.IsPermanent, sp.FileName, pw)
-		pw.CloseWithError(err)
-	}()
-
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", sp.FileName))
-
-	_, err = io.Copy(w, pr)
+	err = writeFile(w, r, sp.FileName, func(w io.Writer) error {
+		return h.file.Get(sp.StorageName, sp.IsPermanent, sp.FileName, w)
+	})
 	if err != nil {
-		h.Error(httperror.NewInternalError("can't open file").WithError(err), w, "GetFileHandler")
+		h.Error(httperror.NewInternalError("can't open file").WithError(err), w, r, "GetFileHandler")
 		return
 	}
 }
@@ -4,41 +4,124 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/listing"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
 )
 
+// FileInfo json representation of file in list, upload, share text and search responses
+type FileInfo struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ModTime     int64  `json:"mod_time"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Version     int64  `json:"version,omitempty"`
+}
+
+// FilePage json representation of paginated file list
+type FilePage struct {
+	Files      []FileInfo `json:"files"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Total      int64      `json:"total"`
+}
+
+func makeFileInfo(files []*file.File) []FileInfo {
+	info := make([]FileInfo, 0, len(files))
+	for _, f := range files {
+		info = append(info, FileInfo{
+			Name:        f.GetName(),
+			Size:        f.GetSize(),
+			ModTime:     f.GetModTime(),
+			ExpiresAt:   f.GetExpiresAt(),
+			ContentType: f.GetContentType(),
+			Version:     f.GetVersion(),
+		})
+	}
+	return info
+}
+
+// isPaginated list is paginated only on request to keep plain json array for old clients
+func isPaginated(r *http.Request) bool {
+	return r.FormValue("page_size") != "" || r.FormValue("cursor") != ""
+}
+
+func (h *Handler) listRequest(r *http.Request, sp storageParameters) (*file.ListRequest, error) {
+	req := &file.ListRequest{
+		Storage:     sp.StorageName,
+		IsPermanent: sp.IsPermanent,
+		Cursor:      r.FormValue("cursor"),
+		Prefix:      r.FormValue("prefix"),
+	}
+
+	var err error
+	if req.Sort, err = listing.ParseSortField(r.FormValue("sort")); err != nil {
+		return nil, err
+	}
+
+	if req.Desc, err = parseOrderParam(r); err != nil {
+		return nil, err
+	}
+
+	pageSize := h.cfg.List.DefaultPageSize
+	if v := r.FormValue("page_size"); v != "" {
+		pageSize, err = strconv.Atoi(v)
+		if err != nil || pageSize <= 0 {
+			return nil, fmt.Errorf("invalid page_size: %s", v)
+		}
+
+		if pageSize > h.cfg.List.MaxPageSize {
+			pageSize = h.cfg.List.MaxPageSize
+		}
+	}
+
+	if isPaginated(r) {
+		req.PageSize = int32(pageSize)
+	}
+
+	return req, nil
+}
+
 // GetFileList returns json encoded file list
+// with page_size or cursor specified returns single page with next_cursor and total count
 func (h *Handler) GetFileList(w http.ResponseWriter, r *http.Request) {
 	sp, err := h.requestParameters(r)
 	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "GetFileList")
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "GetFileList")
 		return
 	}
 
-	files, err := h.file.Files(sp.StorageName, sp.IsPermanent)
+	req, err := h.listRequest(r, sp)
 	if err != nil {
-		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to get files from storage: %s", sp.StorageName)).WithError(err), w, "GetFileList")
+		h.Error(httperror.NewInvalidParams("list params").WithError(err), w, r, "GetFileList")
 		return
 	}
 
-	type JSONInfo struct {
-		Name    string `json:"name"`
-		Size    int64  `json:"size"`
-		ModTime int64  `json:"mod_time"`
+	rsp, err := h.file.FilesPage(req)
+	if err != nil {
+		if listing.IsInvalidCursor(err) {
+			h.Error(httperror.NewInvalidParams("invalid cursor").WithError(err), w, r, "GetFileList")
+			return
+		}
+		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to get files from storage: %s", sp.StorageName)).WithError(err), w, r, "GetFileList")
+		return
 	}
-	info := make([]JSONInfo, 0, len(files))
-	for _, f := range files {
-		info = append(info, JSONInfo{
-			Name:    f.Name,
-			Size:    f.Size,
-			ModTime: f.ModTime,
-		})
+
+	info := makeFileInfo(rsp.GetFiles())
+	var data interface{} = info
+	if isPaginated(r) {
+		data = FilePage{
+			Files:      info,
+			NextCursor: rsp.GetNextCursor(),
+			Total:      rsp.GetTotal(),
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(info); err != nil {
-		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, "GetFileList")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "GetFileList")
 		return
 	}
 }
@@ -1,17 +1,23 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/asim/go-micro/v3"
+	microerrors "github.com/asim/go-micro/v3/errors"
 
+	"github.com/Mikhalevich/filesharing/internal/preview"
 	"github.com/Mikhalevich/filesharing/pkg/ctxinfo"
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
 	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
 	"github.com/Mikhalevich/filesharing/pkg/proto/file"
 	"github.com/Mikhalevich/filesharing/pkg/service"
 )
@@ -23,17 +29,74 @@ const (
 
 type Auther interface {
 	Create(user *auth.User) (*auth.Token, error)
-	Auth(user *auth.User) (*auth.Token, error)
+	Auth(user *auth.User) (*auth.Token, *auth.MFAChallenge, error)
 	AuthPublicUser(name string) (*auth.Token, error)
 	UserByToken(token string) (*auth.User, error)
+	ChangePassword(name, oldPassword, newPassword string) (*auth.Token, error)
+	RequestPasswordReset(name string) (*auth.RequestPasswordResetResponse, error)
+	ResetPassword(name, resetToken, newPassword string) (*auth.Token, error)
+	EnrollMFA(name string) (*auth.EnrollMFAResponse, error)
+	ConfirmMFA(name, code string) (*auth.Token, error)
+	DisableMFA(name string) (*auth.Token, error)
+	VerifyMFA(name, challengeID, code string) (*auth.Token, error)
+	MFAEnrolled(name string) (bool, error)
+	Delete(user *auth.User) error
+	ExistingUsers(names []string) ([]string, error)
 }
 
 type Filer interface {
 	Files(storage string, isPermanent bool) ([]*file.File, error)
+	FilesPage(req *file.ListRequest) (*file.ListResponse, error)
 	Create(storage string, withPermanent bool) error
+	IsStorageExists(storage string) (bool, error)
+	Storages() ([]string, error)
+	RemoveStorage(storage string) error
+	RemoveUnownedStorage(storage string) error
 	Remove(storage string, isPermanent bool, fileName string) error
+	Trash(storage string, isPermanent bool, fileName string) (*file.File, error)
+	TrashFiles(storage string, isPermanent bool) ([]*file.File, error)
+	RestoreFromTrash(storage string, isPermanent bool, fileName string, deletedAt int64) (*file.File, error)
+	EmptyTrash(storage string, isPermanent bool) (int64, error)
 	Get(storage string, isPermanent bool, fileName string, w io.Writer) error
-	Upload(storage string, isPermanent bool, fileName string, r io.Reader) (*file.File, error)
+	GetVersion(storage string, fileName string, version int64, w io.Writer) error
+	Versions(storage string, fileName string) ([]*file.File, error)
+	RestoreVersion(storage string, fileName string, version int64) (*file.File, error)
+	PruneVersions(storage string, fileName string, keep int, maxAge time.Duration) (int64, error)
+	Upload(storage string, isPermanent bool, fileName string, ttl time.Duration, conflict file.ConflictPolicy, r io.Reader) (*file.File, error)
+}
+
+type Historier interface {
+	List(userID int64) ([]*event.FileEvent, error)
+}
+
+type Searcher interface {
+	Search(storage string, isPermanent bool, query string) ([]string, error)
+}
+
+type Previewer interface {
+	Preview(storage string, isPermanent bool, fileName string, size int) (*preview.Preview, error)
+}
+
+type StorageCache interface {
+	Known(name string) bool
+	Add(name string)
+	Remove(name string)
+}
+
+type Limiter interface {
+	Allow(ip, storage string) (time.Duration, error)
+	Locked(ip, storage string) (time.Duration, error)
+	Fail(ip, storage string) (time.Duration, error)
+	Reset(ip, storage string) error
+	ClientIP(r *http.Request) string
+}
+
+type PasswordPolicy interface {
+	Check(name, password string) error
+}
+
+type Mailer interface {
+	SendPasswordReset(to string, name string, token string, expiresAt time.Time) error
 }
 
 type Logger interface {
@@ -45,41 +108,138 @@ type Logger interface {
 	Info(args ...interface{})
 	Warn(args ...interface{})
 	Error(args ...interface{})
+	WithContext(ctx context.Context) service.Logger
 	WithField(key string, value interface{}) service.Logger
 	WithError(err error) service.Logger
 }
 
 // Handler represents gateway handler
 type Handler struct {
-	auth    Auther
-	file    Filer
-	logger  Logger
-	filePub micro.Event
+	auth       Auther
+	file       Filer
+	history    Historier
+	searcher   Searcher
+	previewer  Previewer
+	logger     Logger
+	filePub    micro.Event
+	accountPub micro.Event
+	storagePub micro.Event
+	storages   StorageCache
+	limiter    Limiter
+	policy     PasswordPolicy
+	mailer     Mailer
+	cfg        Config
 }
 
 // NewHandler constructor for Handler
-func NewHandler(a Auther, f Filer, l Logger, filePub micro.Event) *Handler {
+// history is optional, it's used for account export only
+func NewHandler(a Auther, f Filer, hist Historier, s Searcher, p Previewer, l Logger, filePub micro.Event, accountPub micro.Event, storagePub micro.Event, storages StorageCache, limiter Limiter, policy PasswordPolicy, mailer Mailer, cfg Config) *Handler {
 	return &Handler{
-		auth:    a,
-		file:    f,
-		logger:  l,
-		filePub: filePub,
+		auth:       a,
+		file:       f,
+		history:    hist,
+		searcher:   s,
+		previewer:  p,
+		logger:     l,
+		filePub:    filePub,
+		accountPub: accountPub,
+		storagePub: storagePub,
+		storages:   storages,
+		limiter:    limiter,
+		policy:     policy,
+		mailer:     mailer,
+		cfg:        cfg,
 	}
 }
 
-func (h *Handler) Error(err *httperror.Error, w http.ResponseWriter, handler string) {
-	h.logger.WithError(err).
+// Error logs error with request id and user of request and writes it into response
+func (h *Handler) Error(err *httperror.Error, w http.ResponseWriter, r *http.Request, handler string) {
+	h.logger.WithContext(r.Context()).
+		WithError(err).
 		WithField("handler", handler).
 		Error("handler error")
 	err.WriteJSON(w)
 }
 
+func (h *Handler) tooManyRequests(retryAfter time.Duration, w http.ResponseWriter, r *http.Request, handler string) {
+	seconds := int64(retryAfter / time.Second)
+	if retryAfter%time.Second != 0 {
+		seconds++
+	}
+
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	h.Error(httperror.NewTooManyRequests(fmt.Sprintf("too many requests, retry after %d seconds", seconds)), w, r, handler)
+}
+
+// allowRequest checks rate limits for client ip and storage
+// writes error response and returns false if request is not allowed
+func (h *Handler) allowRequest(w http.ResponseWriter, r *http.Request, storage string, handler string) bool {
+	ip := h.limiter.ClientIP(r)
+
+	locked, err := h.limiter.Locked(ip, storage)
+	if err != nil {
+		h.Error(httperror.NewInternalError("rate limit error").WithError(err), w, r, handler)
+		return false
+	}
+
+	if locked > 0 {
+		h.tooManyRequests(locked, w, r, handler)
+		return false
+	}
+
+	wait, err := h.limiter.Allow(ip, storage)
+	if err != nil {
+		h.Error(httperror.NewInternalError("rate limit error").WithError(err), w, r, handler)
+		return false
+	}
+
+	if wait > 0 {
+		h.tooManyRequests(wait, w, r, handler)
+		return false
+	}
+
+	return true
+}
+
+// uploadTTL returns ttl for files uploaded to storage
+// files in permanent storage never expire
+func (h *Handler) uploadTTL(sp storageParameters, requested string) (time.Duration, error) {
+	if sp.IsPermanent {
+		if requested != "" {
+			return 0, errors.New("ttl is not supported for permanent storage")
+		}
+		return 0, nil
+	}
+
+	return h.cfg.TTL.fileTTL(sp.StorageName, requested)
+}
+
+// isSet checks boolean form value
+func isSet(v string) bool {
+	b, err := strconv.ParseBool(v)
+	return err == nil && b
+}
+
+// errorCode returns code of local error or of rpc error returned by service
+// rpc errors lose their type across service boundary, so they are matched by status
 func errorCode(err error) httperror.Code {
 	var httpErr *httperror.Error
 	if errors.As(err, &httpErr) {
 		return httpErr.Code
 	}
 
+	var rpcErr *microerrors.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case http.StatusNotFound:
+			return httperror.CodeNotExist
+		case http.StatusConflict:
+			return httperror.CodeAlreadyExist
+		case http.StatusForbidden:
+			return httperror.CodeForbidden
+		}
+	}
+
 	return httperror.CodeNoError
 }
 
@@ -89,6 +249,8 @@ type storageParameters struct {
 	IsPublic    bool
 	IsPermanent bool
 	FileName    string
+	MFAVerified bool
+	MFAEnrolled bool
 }
 
 func (h *Handler) requestParameters(r *http.Request) (storageParameters, error) {
@@ -128,12 +290,28 @@ func (h *Handler) requestParameters(r *http.Request) (storageParameters, error)
 		return storageParameters{}, fmt.Errorf("unable to get file name: %w", err)
 	}
 
+	mfaVerified, err := ctxinfo.MFAVerified(ctx)
+	if errors.Is(err, ctxinfo.ErrNotFound) {
+		mfaVerified = false
+	} else if err != nil {
+		return storageParameters{}, fmt.Errorf("unable to get mfa verified: %w", err)
+	}
+
+	mfaEnrolled, err := ctxinfo.MFAEnrolled(ctx)
+	if errors.Is(err, ctxinfo.ErrNotFound) {
+		mfaEnrolled = false
+	} else if err != nil {
+		return storageParameters{}, fmt.Errorf("unable to get mfa enrolled: %w", err)
+	}
+
 	return storageParameters{
 		UserID:      userID,
 		StorageName: storage,
 		IsPublic:    isPublic,
 		IsPermanent: isPermanent,
 		FileName:    fileName,
+		MFAVerified: mfaVerified,
+		MFAEnrolled: mfaEnrolled,
 	}, nil
 }
 
@@ -151,7 +329,7 @@ func (h *Handler) RecoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if e, ok := recover().(error); ok {
-				h.Error(httperror.NewInternalError("panic recovered").WithError(e), w, "RecoverHandler")
+				h.Error(httperror.NewInternalError("panic recovered").WithError(e), w, r, "RecoverHandler")
 				return
 			}
 		}()
@@ -178,49 +356,59 @@ func (h *Handler) CheckAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		p, err := h.requestParameters(r)
 		if err != nil {
-			h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "CheckAuthMiddleware")
-			return
-		}
-
-		if p.IsPublic {
-			next.ServeHTTP(w, r)
+			h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "CheckAuthMiddleware")
 			return
 		}
 
 		if p.StorageName == "" {
-			h.Error(httperror.NewInvalidParams("storage name is empty"), w, "CheckAuthMiddleware")
+			h.Error(httperror.NewInvalidParams("storage name is empty"), w, r, "CheckAuthMiddleware")
 			return
 		}
 
+		var user *auth.User
 		token := extractToken(r)
-		if token == "" {
+		if token != "" {
+			user, err = h.auth.UserByToken(token)
+		}
+
+		if token == "" || err != nil {
+			if !h.cfg.Storages.Public {
+				if token == "" {
+					h.Error(httperror.NewUnauthorized("token is required"), w, r, "CheckAuthMiddleware")
+				} else {
+					h.Error(httperror.NewUnauthorized("unable to get user by token").WithError(err), w, r, "CheckAuthMiddleware")
+				}
+				return
+			}
+
+			if !h.allowRequest(w, r, p.StorageName, "CheckAuthMiddleware") {
+				return
+			}
+
 			t, err := h.auth.AuthPublicUser(p.StorageName)
 			if err != nil {
-				h.Error(httperror.NewUnauthorized("unable to get token").WithError(err), w, "CheckAuthMiddleware")
+				h.Error(httperror.NewUnauthorized("unable to get token").WithError(err), w, r, "CheckAuthMiddleware")
 				return
 			}
 			token = t.GetValue()
 			w.Header().Set("X-Token", token)
-		}
 
-		user, err := h.auth.UserByToken(token)
-		if err != nil {
-			t, err := h.auth.AuthPublicUser(p.StorageName)
+			user, err = h.auth.UserByToken(token)
 			if err != nil {
-				h.Error(httperror.NewUnauthorized("unable to get user by token").WithError(err), w, "CheckAuthMiddleware")
+				h.Error(httperror.NewUnauthorized("unable to get user by token").WithError(err), w, r, "CheckAuthMiddleware")
 				return
 			}
-			token = t.GetValue()
-			w.Header().Set("X-Token", token)
 		}
 
 		if user.Name != p.StorageName {
-			h.Error(httperror.NewNotMatchError(fmt.Sprintf("invalid request user = %s, storage = %s", user, p.StorageName)), w, "CheckAuthMiddleware")
+			h.Error(httperror.NewNotMatchError(fmt.Sprintf("invalid request user = %s, storage = %s", user, p.StorageName)), w, r, "CheckAuthMiddleware")
 			return
 		}
 
 		ctx := ctxinfo.WithUserID(r.Context(), user.Id)
-		if !p.IsPublic && user.Public {
+		ctx = ctxinfo.WithMFAVerified(ctx, user.MfaVerified)
+		ctx = ctxinfo.WithMFAEnrolled(ctx, user.MfaEnrolled)
+		if user.Public {
 			ctx = ctxinfo.WithPublicStorage(ctx, true)
 		}
 		r = r.WithContext(ctx)
@@ -229,29 +417,88 @@ func (h *Handler) CheckAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// CreateStorageMiddleware middleware check storage for existence
+// RequireMFAMiddleware middleware rejects requests authorized without second factor
+// storages without enrolled second factor, public ones included, are protected by password only
+// enrollment is checked by auth service, token issued before enrollment has no enrollment claim
+func (h *Handler) RequireMFAMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := h.requestParameters(r)
+		if err != nil {
+			h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "RequireMFAMiddleware")
+			return
+		}
+
+		if !p.MFAVerified && !p.IsPublic {
+			enrolled, err := h.auth.MFAEnrolled(p.StorageName)
+			if err != nil {
+				h.Error(httperror.NewInternalError("mfa status error").WithError(err), w, r, "RequireMFAMiddleware")
+				return
+			}
+
+			if enrolled {
+				h.Error(httperror.NewUnauthorized("two-factor authentication required"), w, r, "RequireMFAMiddleware")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CreateStorageMiddleware middleware creates storage on first authorized write
 func (h *Handler) CreateStorageMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		p, err := h.requestParameters(r)
 		if err != nil {
-			h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "CreateStorageMiddleware")
+			h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "CreateStorageMiddleware")
 			return
 		}
-		err = h.createIfNotExist(p.StorageName, true)
+
+		if p.StorageName == "" {
+			h.Error(httperror.NewInvalidParams("storage name is empty"), w, r, "CreateStorageMiddleware")
+			return
+		}
+		err = h.ensureStorage(p.StorageName)
 		if err != nil {
-			h.Error(httperror.NewInternalError("unable to create storage").WithError(err), w, "CreateStorageMiddleware")
+			h.Error(httperror.NewInternalError("unable to create storage").WithError(err), w, r, "CreateStorageMiddleware")
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (h *Handler) createIfNotExist(name string, isPermanent bool) error {
-	err := h.file.Create(name, isPermanent)
+// ensureStorage creates storage if it doesn't exist
+// file service is asked only for storages not found in local cache
+func (h *Handler) ensureStorage(name string) error {
+	if h.storages.Known(name) {
+		return nil
+	}
+
+	exists, err := h.file.IsStorageExists(name)
 	if err != nil {
-		if errorCode(err) == httperror.CodeAlreadyExist {
-			return nil
+		return err
+	}
+
+	if !exists {
+		if err := h.file.Create(name, true); err != nil && errorCode(err) != httperror.CodeAlreadyExist {
+			return err
 		}
+		h.publishStorageEvent(name, event.StorageAction_StorageCreated)
 	}
-	return err
+
+	h.storages.Add(name)
+	return nil
+}
+
+// publishStorageEvent notifies other gateways about created or removed storage
+func (h *Handler) publishStorageEvent(name string, action event.StorageAction) {
+	go func() {
+		if err := h.storagePub.Publish(context.Background(), &event.StorageEvent{
+			Name:   name,
+			Time:   time.Now().Unix(),
+			Action: action,
+		}); err != nil {
+			h.logger.WithError(err).WithField("storage", name).Error("unable to publish storage event")
+		}
+	}()
 }
@@ -11,7 +11,7 @@ import (
 func (h *Handler) IndexHTMLHandler(w http.ResponseWriter, r *http.Request) {
 	sp, err := h.requestParameters(r)
 	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "IndexHTMLHandler")
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "IndexHTMLHandler")
 		return
 	}
 
@@ -24,7 +24,7 @@ func (h *Handler) IndexHTMLHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-type", "text/html")
 	_, err = io.Copy(w, pr)
 	if err != nil {
-		h.Error(httperror.NewInternalError("can't open index.html").WithError(err), w, "IndexHTMLHandler")
+		h.Error(httperror.NewInternalError("can't open index.html").WithError(err), w, r, "IndexHTMLHandler")
 		return
 	}
 }
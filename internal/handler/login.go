@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
@@ -12,27 +11,26 @@ import (
 func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	sp, err := h.requestParameters(r)
 	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "LoginHandler")
-		return
-	}
-
-	if sp.IsPublic {
-		h.Error(httperror.NewInvalidParams(fmt.Sprintf("no need to login into %s", sp.StorageName)), w, "LoginHandler")
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "LoginHandler")
 		return
 	}
 
 	if sp.StorageName == "" {
-		h.Error(httperror.NewInvalidParams("invalid storage name"), w, "LoginHandler")
+		h.Error(httperror.NewInvalidParams("invalid storage name"), w, r, "LoginHandler")
 		return
 	}
 
 	password := r.FormValue("password")
 	if password == "" {
-		h.Error(httperror.NewInvalidParams("invalid password"), w, "LoginHandler")
+		h.Error(httperror.NewInvalidParams("invalid password"), w, r, "LoginHandler")
 		return
 	}
 
-	token, err := h.auth.Auth(&auth.User{
+	if !h.allowRequest(w, r, sp.StorageName, "LoginHandler") {
+		return
+	}
+
+	token, challenge, err := h.auth.Auth(&auth.User{
 		Name:     sp.StorageName,
 		Password: password,
 	})
@@ -40,17 +38,37 @@ func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch errorCode(err) {
 		case httperror.CodeNotExist:
-			h.Error(httperror.NewNotExistError("no such storage"), w, "LoginHandler")
+			h.Error(httperror.NewNotExistError("no such storage"), w, r, "LoginHandler")
 
 		case httperror.CodeNotMatch:
-			h.Error(httperror.NewNotMatchError("not match"), w, "LoginHandler")
+			locked, err := h.limiter.Fail(h.limiter.ClientIP(r), sp.StorageName)
+			if err != nil {
+				h.Error(httperror.NewInternalError("rate limit error").WithError(err), w, r, "LoginHandler")
+				return
+			}
+
+			if locked > 0 {
+				h.tooManyRequests(locked, w, r, "LoginHandler")
+				return
+			}
+
+			h.Error(httperror.NewNotMatchError("not match"), w, r, "LoginHandler")
 
 		default:
-			h.Error(httperror.NewInternalError("auth error").WithError(err), w, "LoginHandler")
+			h.Error(httperror.NewInternalError("auth error").WithError(err), w, r, "LoginHandler")
 		}
 		return
 	}
 
+	if challenge != nil {
+		h.writeMFAChallenge(challenge, w)
+		return
+	}
+
+	if err := h.limiter.Reset(h.limiter.ClientIP(r), sp.StorageName); err != nil {
+		h.logger.WithError(err).Error("unable to reset rate limit")
+	}
+
 	w.Write([]byte(token.Value))
 	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
+	"github.com/Mikhalevich/filesharing/pkg/totp"
+)
+
+// writeMFAChallenge responds with challenge which should be completed on /login/mfa/
+func (h *Handler) writeMFAChallenge(c *auth.MFAChallenge, w http.ResponseWriter) {
+	type JSONChallenge struct {
+		Challenge string `json:"challenge"`
+		ExpiresAt int64  `json:"expires_at"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(JSONChallenge{
+		Challenge: c.GetId(),
+		ExpiresAt: c.GetExpiresAt(),
+	}); err != nil {
+		h.logger.WithError(err).Error("unable to encode mfa challenge")
+	}
+}
+
+// LoginMFAHandler completes login challenge with totp or recovery code
+func (h *Handler) LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "LoginMFAHandler")
+		return
+	}
+
+	if sp.StorageName == "" {
+		h.Error(httperror.NewInvalidParams("invalid storage name"), w, r, "LoginMFAHandler")
+		return
+	}
+
+	challenge := r.FormValue("challenge")
+	if challenge == "" {
+		h.Error(httperror.NewInvalidParams("invalid challenge"), w, r, "LoginMFAHandler")
+		return
+	}
+
+	code := r.FormValue("code")
+	if code == "" {
+		h.Error(httperror.NewInvalidParams("invalid code"), w, r, "LoginMFAHandler")
+		return
+	}
+
+	if !h.allowRequest(w, r, sp.StorageName, "LoginMFAHandler") {
+		return
+	}
+
+	token, err := h.auth.VerifyMFA(sp.StorageName, challenge, code)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist, httperror.CodeNotMatch:
+			locked, err := h.limiter.Fail(h.limiter.ClientIP(r), sp.StorageName)
+			if err != nil {
+				h.Error(httperror.NewInternalError("rate limit error").WithError(err), w, r, "LoginMFAHandler")
+				return
+			}
+
+			if locked > 0 {
+				h.tooManyRequests(locked, w, r, "LoginMFAHandler")
+				return
+			}
+
+			h.Error(httperror.NewNotMatchError("invalid code or expired challenge"), w, r, "LoginMFAHandler")
+
+		default:
+			h.Error(httperror.NewInternalError("verify mfa error").WithError(err), w, r, "LoginMFAHandler")
+		}
+		return
+	}
+
+	if err := h.limiter.Reset(h.limiter.ClientIP(r), sp.StorageName); err != nil {
+		h.logger.WithError(err).Error("unable to reset rate limit")
+	}
+
+	w.Write([]byte(token.Value))
+	w.WriteHeader(http.StatusOK)
+}
+
+// EnrollMFAHandler starts totp enrollment for storage
+// enrollment becomes active after confirmation on /mfa/confirm/
+func (h *Handler) EnrollMFAHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "EnrollMFAHandler")
+		return
+	}
+
+	if sp.IsPublic {
+		h.Error(httperror.NewInvalidParams("two-factor authentication is not available for public storage"), w, r, "EnrollMFAHandler")
+		return
+	}
+
+	rsp, err := h.auth.EnrollMFA(sp.StorageName)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeAlreadyExist:
+			h.Error(httperror.NewAlreadyExistError("two-factor authentication already enabled"), w, r, "EnrollMFAHandler")
+		default:
+			h.Error(httperror.NewInternalError("enroll mfa error").WithError(err), w, r, "EnrollMFAHandler")
+		}
+		return
+	}
+
+	type JSONEnrollment struct {
+		URI           string   `json:"otpauth_uri"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(JSONEnrollment{
+		URI:           totp.URI(h.cfg.MFAIssuer, sp.StorageName, rsp.GetSecret()),
+		RecoveryCodes: rsp.GetRecoveryCodes(),
+	}); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "EnrollMFAHandler")
+		return
+	}
+}
+
+// ConfirmMFAHandler activates totp enrollment with the first code
+func (h *Handler) ConfirmMFAHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "ConfirmMFAHandler")
+		return
+	}
+
+	if sp.IsPublic {
+		h.Error(httperror.NewInvalidParams("two-factor authentication is not available for public storage"), w, r, "ConfirmMFAHandler")
+		return
+	}
+
+	code := r.FormValue("code")
+	if code == "" {
+		h.Error(httperror.NewInvalidParams("invalid code"), w, r, "ConfirmMFAHandler")
+		return
+	}
+
+	if !h.allowRequest(w, r, sp.StorageName, "ConfirmMFAHandler") {
+		return
+	}
+
+	token, err := h.auth.ConfirmMFA(sp.StorageName, code)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError("no pending enrollment"), w, r, "ConfirmMFAHandler")
+		case httperror.CodeNotMatch:
+			locked, err := h.limiter.Fail(h.limiter.ClientIP(r), sp.StorageName)
+			if err != nil {
+				h.Error(httperror.NewInternalError("rate limit error").WithError(err), w, r, "ConfirmMFAHandler")
+				return
+			}
+
+			if locked > 0 {
+				h.tooManyRequests(locked, w, r, "ConfirmMFAHandler")
+				return
+			}
+
+			h.Error(httperror.NewNotMatchError("invalid code"), w, r, "ConfirmMFAHandler")
+		default:
+			h.Error(httperror.NewInternalError("confirm mfa error").WithError(err), w, r, "ConfirmMFAHandler")
+		}
+		return
+	}
+
+	if err := h.limiter.Reset(h.limiter.ClientIP(r), sp.StorageName); err != nil {
+		h.logger.WithError(err).Error("unable to reset rate limit")
+	}
+
+	w.Write([]byte(token.Value))
+	w.WriteHeader(http.StatusOK)
+}
+
+// DisableMFAHandler disables two-factor authentication for storage
+func (h *Handler) DisableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "DisableMFAHandler")
+		return
+	}
+
+	if !sp.MFAEnrolled {
+		h.Error(httperror.NewNotExistError("two-factor authentication is not enabled"), w, r, "DisableMFAHandler")
+		return
+	}
+
+	token, err := h.auth.DisableMFA(sp.StorageName)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError("two-factor authentication is not enabled"), w, r, "DisableMFAHandler")
+		default:
+			h.Error(httperror.NewInternalError("disable mfa error").WithError(err), w, r, "DisableMFAHandler")
+		}
+		return
+	}
+
+	w.Write([]byte(token.Value))
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
+)
+
+// writeSession signs in with new password, storages with second factor get login challenge instead of token
+func (h *Handler) writeSession(name, password string, w http.ResponseWriter, r *http.Request, handlerName string) {
+	token, challenge, err := h.auth.Auth(&auth.User{
+		Name:     name,
+		Password: password,
+	})
+	if err != nil {
+		h.Error(httperror.NewInternalError("unable to sign in with new password").WithError(err), w, r, handlerName)
+		return
+	}
+
+	if challenge != nil {
+		h.writeMFAChallenge(challenge, w)
+		return
+	}
+
+	w.Write([]byte(token.Value))
+	w.WriteHeader(http.StatusOK)
+}
+
+// mfaVerified checks token of public route, it should belong to storage and be completed with second factor
+func (h *Handler) mfaVerified(r *http.Request, name string) bool {
+	token := extractToken(r)
+	if token == "" {
+		return false
+	}
+
+	user, err := h.auth.UserByToken(token)
+	if err != nil {
+		return false
+	}
+
+	return user.Name == name && user.MfaVerified
+}
+
+// ChangePasswordHandler changes password for the existing storage(user)
+func (h *Handler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "ChangePasswordHandler")
+		return
+	}
+
+	if sp.StorageName == "" {
+		h.Error(httperror.NewInvalidParams("invalid storage name"), w, r, "ChangePasswordHandler")
+		return
+	}
+
+	oldPassword := r.FormValue("old_password")
+	if oldPassword == "" {
+		h.Error(httperror.NewInvalidParams("invalid old password"), w, r, "ChangePasswordHandler")
+		return
+	}
+
+	newPassword := r.FormValue("new_password")
+	if err := h.policy.Check(sp.StorageName, newPassword); err != nil {
+		h.Error(httperror.NewInvalidParams("invalid new password").WithError(err), w, r, "ChangePasswordHandler")
+		return
+	}
+
+	if !h.allowRequest(w, r, sp.StorageName, "ChangePasswordHandler") {
+		return
+	}
+
+	// old password alone is not enough for storages with second factor
+	enrolled, err := h.auth.MFAEnrolled(sp.StorageName)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError("no such storage"), w, r, "ChangePasswordHandler")
+		default:
+			h.Error(httperror.NewInternalError("mfa status error").WithError(err), w, r, "ChangePasswordHandler")
+		}
+		return
+	}
+
+	if enrolled && !h.mfaVerified(r, sp.StorageName) {
+		h.Error(httperror.NewUnauthorized("two-factor authentication required"), w, r, "ChangePasswordHandler")
+		return
+	}
+
+	_, err = h.auth.ChangePassword(sp.StorageName, oldPassword, newPassword)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError("no such storage"), w, r, "ChangePasswordHandler")
+
+		case httperror.CodeNotMatch:
+			locked, err := h.limiter.Fail(h.limiter.ClientIP(r), sp.StorageName)
+			if err != nil {
+				h.Error(httperror.NewInternalError("rate limit error").WithError(err), w, r, "ChangePasswordHandler")
+				return
+			}
+
+			if locked > 0 {
+				h.tooManyRequests(locked, w, r, "ChangePasswordHandler")
+				return
+			}
+
+			h.Error(httperror.NewNotMatchError("old password not match"), w, r, "ChangePasswordHandler")
+
+		default:
+			h.Error(httperror.NewInternalError("change password error").WithError(err), w, r, "ChangePasswordHandler")
+		}
+		return
+	}
+
+	if err := h.limiter.Reset(h.limiter.ClientIP(r), sp.StorageName); err != nil {
+		h.logger.WithError(err).Error("unable to reset rate limit")
+	}
+
+	h.writeSession(sp.StorageName, newPassword, w, r, "ChangePasswordHandler")
+}
+
+// RequestPasswordResetHandler sends password reset token to storage email
+// response is the same for unknown storages to prevent storage enumeration
+func (h *Handler) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "RequestPasswordResetHandler")
+		return
+	}
+
+	if sp.StorageName == "" {
+		h.Error(httperror.NewInvalidParams("invalid storage name"), w, r, "RequestPasswordResetHandler")
+		return
+	}
+
+	if !h.allowRequest(w, r, sp.StorageName, "RequestPasswordResetHandler") {
+		return
+	}
+
+	rsp, err := h.auth.RequestPasswordReset(sp.StorageName)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.logger.WithField("storage", sp.StorageName).Warn("password reset for unknown storage")
+			w.WriteHeader(http.StatusOK)
+
+		default:
+			h.Error(httperror.NewInternalError("request password reset error").WithError(err), w, r, "RequestPasswordResetHandler")
+		}
+		return
+	}
+
+	if rsp.GetEmail() == "" {
+		h.logger.WithField("storage", sp.StorageName).Warn("password reset for storage without email")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := h.mailer.SendPasswordReset(rsp.GetEmail(), sp.StorageName, rsp.GetResetToken(), time.Unix(rsp.GetExpiresAt(), 0)); err != nil {
+		h.Error(httperror.NewInternalError("unable to send reset email").WithError(err), w, r, "RequestPasswordResetHandler")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ResetPasswordHandler sets new password using reset token
+func (h *Handler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "ResetPasswordHandler")
+		return
+	}
+
+	if sp.StorageName == "" {
+		h.Error(httperror.NewInvalidParams("invalid storage name"), w, r, "ResetPasswordHandler")
+		return
+	}
+
+	resetToken := r.FormValue("token")
+	if resetToken == "" {
+		h.Error(httperror.NewInvalidParams("invalid reset token"), w, r, "ResetPasswordHandler")
+		return
+	}
+
+	newPassword := r.FormValue("new_password")
+	if err := h.policy.Check(sp.StorageName, newPassword); err != nil {
+		h.Error(httperror.NewInvalidParams("invalid new password").WithError(err), w, r, "ResetPasswordHandler")
+		return
+	}
+
+	if !h.allowRequest(w, r, sp.StorageName, "ResetPasswordHandler") {
+		return
+	}
+
+	_, err = h.auth.ResetPassword(sp.StorageName, resetToken, newPassword)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist, httperror.CodeNotMatch:
+			if _, err := h.limiter.Fail(h.limiter.ClientIP(r), sp.StorageName); err != nil {
+				h.logger.WithError(err).Error("unable to register failed attempt")
+			}
+			h.Error(httperror.NewNotMatchError("invalid or expired reset token"), w, r, "ResetPasswordHandler")
+
+		default:
+			h.Error(httperror.NewInternalError("reset password error").WithError(err), w, r, "ResetPasswordHandler")
+		}
+		return
+	}
+
+	h.writeSession(sp.StorageName, newPassword, w, r, "ResetPasswordHandler")
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Mikhalevich/filesharing/internal/preview"
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+)
+
+// PreviewHandler returns image thumbnail of requested size or text snippet
+func (h *Handler) PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "PreviewHandler")
+		return
+	}
+
+	if sp.FileName == "" {
+		h.Error(httperror.NewInvalidParams("file name").WithError(errors.New("file name is empty")), w, r, "PreviewHandler")
+		return
+	}
+
+	size := 0
+	if v := r.FormValue("size"); v != "" {
+		size, err = strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			h.Error(httperror.NewInvalidParams("size").WithError(fmt.Errorf("invalid size: %s", v)), w, r, "PreviewHandler")
+			return
+		}
+	}
+
+	p, err := h.previewer.Preview(sp.StorageName, sp.IsPermanent, sp.FileName, size)
+	if err != nil {
+		if errors.Is(err, preview.ErrNotSupported) || errors.Is(err, preview.ErrTooLarge) {
+			h.Error(httperror.NewInvalidParams("preview").WithError(err), w, r, "PreviewHandler")
+			return
+		}
+		h.Error(httperror.NewInternalError("unable to get preview").WithError(err), w, r, "PreviewHandler")
+		return
+	}
+
+	w.Header().Set("Content-Type", p.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if _, err := w.Write(p.Data); err != nil {
+		h.logger.WithError(err).Error("unable to write preview")
+	}
+}
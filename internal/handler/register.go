@@ -2,43 +2,56 @@ package handler
 
 import (
 	"net/http"
+	"net/mail"
 
+	"github.com/Mikhalevich/filesharing/internal/names"
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
 	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
 )
 
 // RegisterHandler register a new storage(user)
 func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	storageName := r.FormValue("name")
 	password := r.FormValue("password")
+	email := r.FormValue("email")
 
-	if storageName == "" {
-		h.Error(httperror.NewInvalidParams("invalid storage name"), w, "RegisterHandler")
+	storageName, err := names.NormalizeStorage(storageName)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid storage name").WithError(err), w, r, "RegisterHandler")
 		return
 	}
 
-	sp, err := h.requestParameters(r)
-	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "RegisterHandler")
+	if err := h.policy.Check(storageName, password); err != nil {
+		h.Error(httperror.NewInvalidParams("invalid password").WithError(err), w, r, "RegisterHandler")
 		return
 	}
 
-	if sp.IsPublic {
-		h.Error(httperror.NewAlreadyExistError("storage with this name already exists"), w, "RegisterHandler")
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			h.Error(httperror.NewInvalidParams("invalid email").WithError(err), w, r, "RegisterHandler")
+			return
+		}
+		email = addr.Address
+	}
+
+	if !h.allowRequest(w, r, storageName, "RegisterHandler") {
 		return
 	}
 
 	token, err := h.auth.Create(&auth.User{
 		Name:     storageName,
+		Email:    email,
 		Password: password,
 	})
 
 	if err != nil {
 		switch errorCode(err) {
 		case httperror.CodeAlreadyExist:
-			h.Error(httperror.NewAlreadyExistError("storage with this name already exists"), w, "RegisterHandler")
+			h.Error(httperror.NewAlreadyExistError("storage with this name already exists"), w, r, "RegisterHandler")
 		default:
-			h.Error(httperror.NewInternalError("create user error").WithError(err), w, "RegisterHandler")
+			h.Error(httperror.NewInternalError("create user error").WithError(err), w, r, "RegisterHandler")
 		}
 		return
 	}
@@ -49,12 +62,15 @@ func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch errorCode(err) {
 		case httperror.CodeAlreadyExist:
-			h.Error(httperror.NewInternalError("unable to create storage"), w, "RegisterHandler")
+			h.Error(httperror.NewInternalError("unable to create storage"), w, r, "RegisterHandler")
 		default:
-			h.Error(httperror.NewInternalError("create file error").WithError(err), w, "RegisterHandler")
+			h.Error(httperror.NewInternalError("create file error").WithError(err), w, r, "RegisterHandler")
 		}
 		return
 	}
 
+	h.storages.Add(storageName)
+	h.publishStorageEvent(storageName, event.StorageAction_StorageCreated)
+
 	w.WriteHeader(http.StatusOK)
 }
@@ -6,42 +6,54 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Mikhalevich/filesharing/internal/names"
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
 	"github.com/Mikhalevich/filesharing/pkg/proto/event"
 )
 
-// RemoveHandler removes current file from storage
+// RemoveHandler moves current file to storage trash
+// file is removed permanently if force parameter is set
 func (h *Handler) RemoveHandler(w http.ResponseWriter, r *http.Request) {
-	fileName := r.FormValue("fileName")
-	if fileName == "" {
-		h.Error(httperror.NewInvalidParams("file name was not set"), w, "RemoveHandler")
+	if r.FormValue("fileName") == "" {
+		h.Error(httperror.NewInvalidParams("file name was not set"), w, r, "RemoveHandler")
+		return
+	}
+
+	fileName, err := names.NormalizeFile(r.FormValue("fileName"))
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid file name").WithError(err), w, r, "RemoveHandler")
 		return
 	}
 
 	sp, err := h.requestParameters(r)
 	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "RemoveHandler")
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "RemoveHandler")
 		return
 	}
 
-	err = h.file.Remove(sp.StorageName, sp.IsPermanent, fileName)
+	if isSet(r.FormValue("force")) {
+		err = h.file.Remove(sp.StorageName, sp.IsPermanent, fileName)
+	} else {
+		_, err = h.file.Trash(sp.StorageName, sp.IsPermanent, fileName)
+	}
 	// if err == fs.ErrNotExists {
 	// 	h.respondWithError(fileNotExistError(fileName), w, "file name doesn't exist", http.StatusBadRequest)
 	// 	return
 	// }
 
 	if err != nil {
-		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to remove file: %s from storage: %s", fileName, sp.StorageName)).WithError(err), w, "RemoveHandler")
+		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to remove file: %s from storage: %s", fileName, sp.StorageName)).WithError(err), w, r, "RemoveHandler")
 		return
 	}
 
 	go func() {
 		h.filePub.Publish(context.Background(), &event.FileEvent{
-			UserID:   sp.UserID,
-			UserName: sp.StorageName,
-			FileName: fileName,
-			Time:     time.Now().Unix(),
-			Action:   event.Action_Remove,
+			UserID:      sp.UserID,
+			UserName:    sp.StorageName,
+			FileName:    fileName,
+			Time:        time.Now().Unix(),
+			Action:      event.Action_Remove,
+			IsPermanent: sp.IsPermanent,
 		})
 	}()
 
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Mikhalevich/filesharing/internal/search"
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/listing"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+)
+
+// indexingRetryAfter seconds to wait before retrying search in storage being indexed
+const indexingRetryAfter = 5
+
+type searchParameters struct {
+	Name    string
+	Query   string
+	MinSize int64
+	MaxSize int64
+	From    int64
+	To      int64
+	Sort    file.SortField
+	Desc    bool
+}
+
+func parseInt64Param(r *http.Request, name string) (int64, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, v)
+	}
+
+	return i, nil
+}
+
+func searchRequestParameters(r *http.Request) (searchParameters, error) {
+	p := searchParameters{
+		Name:  r.FormValue("name"),
+		Query: r.FormValue("q"),
+	}
+
+	var err error
+	if p.MinSize, err = parseInt64Param(r, "min_size"); err != nil {
+		return searchParameters{}, err
+	}
+
+	if p.MaxSize, err = parseInt64Param(r, "max_size"); err != nil {
+		return searchParameters{}, err
+	}
+
+	if p.From, err = parseInt64Param(r, "from"); err != nil {
+		return searchParameters{}, err
+	}
+
+	if p.To, err = parseInt64Param(r, "to"); err != nil {
+		return searchParameters{}, err
+	}
+
+	if p.Sort, err = listing.ParseSortField(r.FormValue("sort")); err != nil {
+		return searchParameters{}, err
+	}
+
+	if p.Desc, err = parseOrderParam(r); err != nil {
+		return searchParameters{}, err
+	}
+
+	if p.Name != "" && isGlob(p.Name) {
+		if _, err := path.Match(p.Name, ""); err != nil {
+			return searchParameters{}, fmt.Errorf("invalid name pattern: %s", p.Name)
+		}
+	}
+
+	return p, nil
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// matchName matches glob pattern or case insensitive substring
+func matchName(pattern string, name string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	if isGlob(pattern) {
+		ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+		return ok
+	}
+
+	return strings.Contains(strings.ToLower(name), strings.ToLower(pattern))
+}
+
+func (p searchParameters) match(f *file.File) bool {
+	if !matchName(p.Name, f.GetName()) {
+		return false
+	}
+
+	if p.MinSize > 0 && f.GetSize() < p.MinSize {
+		return false
+	}
+
+	if p.MaxSize > 0 && f.GetSize() > p.MaxSize {
+		return false
+	}
+
+	if p.From > 0 && f.GetModTime() < p.From {
+		return false
+	}
+
+	if p.To > 0 && f.GetModTime() > p.To {
+		return false
+	}
+
+	return true
+}
+
+func parseOrderParam(r *http.Request) (bool, error) {
+	switch order := r.FormValue("order"); order {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid order: %s", order)
+	}
+}
+
+// SearchHandler returns json encoded list of files matched by name, size, date and content
+func (h *Handler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "SearchHandler")
+		return
+	}
+
+	p, err := searchRequestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("search params").WithError(err), w, r, "SearchHandler")
+		return
+	}
+
+	files, err := h.file.Files(sp.StorageName, sp.IsPermanent)
+	if err != nil {
+		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to get files from storage: %s", sp.StorageName)).WithError(err), w, r, "SearchHandler")
+		return
+	}
+
+	var contentMatches map[string]bool
+	if p.Query != "" {
+		names, err := h.searcher.Search(sp.StorageName, sp.IsPermanent, p.Query)
+		if errors.Is(err, search.ErrIndexing) {
+			w.Header().Set("Retry-After", strconv.Itoa(indexingRetryAfter))
+			h.Error(httperror.NewUnavailable("storage is being indexed, retry later"), w, r, "SearchHandler")
+			return
+		} else if err != nil {
+			h.Error(httperror.NewInternalError("full text search error").WithError(err), w, r, "SearchHandler")
+			return
+		}
+
+		contentMatches = make(map[string]bool, len(names))
+		for _, n := range names {
+			contentMatches[n] = true
+		}
+	}
+
+	matched := make([]*file.File, 0, len(files))
+	for _, f := range files {
+		if !p.match(f) {
+			continue
+		}
+
+		if contentMatches != nil && !contentMatches[f.GetName()] {
+			continue
+		}
+
+		matched = append(matched, f)
+	}
+
+	listing.Sort(matched, p.Sort, p.Desc)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(makeFileInfo(matched)); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "SearchHandler")
+		return
+	}
+}
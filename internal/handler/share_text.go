@@ -1,33 +1,61 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/Mikhalevich/filesharing/internal/names"
+	"github.com/Mikhalevich/filesharing/pkg/conflict"
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
 )
 
 // ShareTextHandler crate file from share text request
+// returns json encoded stored file, its name differs from title with rename conflict policy
 func (h *Handler) ShareTextHandler(w http.ResponseWriter, r *http.Request) {
 	title := r.FormValue("title")
 	body := r.FormValue("body")
 
 	if title == "" || body == "" {
-		h.Error(httperror.NewInvalidParams(fmt.Sprintf("title or body was not set; title = %s body = %s", title, body)), w, "ShareTextHandler")
+		h.Error(httperror.NewInvalidParams(fmt.Sprintf("title or body was not set; title = %s body = %s", title, body)), w, r, "ShareTextHandler")
+		return
+	}
+
+	title, err := names.NormalizeFile(title)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid title").WithError(err), w, r, "ShareTextHandler")
 		return
 	}
 
 	sp, err := h.requestParameters(r)
 	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "ShareTextHandler")
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "ShareTextHandler")
+		return
+	}
+
+	ttl, err := h.uploadTTL(sp, r.FormValue("ttl"))
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid ttl").WithError(err), w, r, "ShareTextHandler")
 		return
 	}
 
-	_, err = h.file.Upload(sp.StorageName, sp.IsPermanent, title, strings.NewReader(body))
+	policy, err := conflict.ParsePolicy(r.FormValue("conflict"))
 	if err != nil {
-		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to store text file: %s for storage: %s", title, sp.StorageName)).WithError(err), w, "ShareTextHandler")
+		h.Error(httperror.NewInvalidParams("invalid conflict").WithError(err), w, r, "ShareTextHandler")
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	f, uploadErr := h.uploadFile(sp, ttl, policy, title, strings.NewReader(body))
+	if uploadErr != nil {
+		h.Error(uploadErr, w, r, "ShareTextHandler")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(makeFileInfo([]*file.File{f})[0]); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "ShareTextHandler")
+		return
+	}
 }
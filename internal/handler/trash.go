@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mikhalevich/filesharing/internal/names"
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+)
+
+// GetTrashListHandler returns json encoded list of files in storage trash
+func (h *Handler) GetTrashListHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "GetTrashListHandler")
+		return
+	}
+
+	files, err := h.file.TrashFiles(sp.StorageName, sp.IsPermanent)
+	if err != nil {
+		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to get trash files from storage: %s", sp.StorageName)).WithError(err), w, r, "GetTrashListHandler")
+		return
+	}
+
+	type JSONInfo struct {
+		Name      string `json:"name"`
+		Size      int64  `json:"size"`
+		ModTime   int64  `json:"mod_time"`
+		DeletedAt int64  `json:"deleted_at"`
+	}
+	info := make([]JSONInfo, 0, len(files))
+	for _, f := range files {
+		info = append(info, JSONInfo{
+			Name:      f.Name,
+			Size:      f.Size,
+			ModTime:   f.ModTime,
+			DeletedAt: f.DeletedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "GetTrashListHandler")
+		return
+	}
+}
+
+// RestoreTrashHandler moves file from trash back to storage
+func (h *Handler) RestoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("fileName") == "" {
+		h.Error(httperror.NewInvalidParams("file name was not set"), w, r, "RestoreTrashHandler")
+		return
+	}
+
+	fileName, err := names.NormalizeFile(r.FormValue("fileName"))
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid file name").WithError(err), w, r, "RestoreTrashHandler")
+		return
+	}
+
+	var deletedAt int64
+	if v := r.FormValue("deleted_at"); v != "" {
+		deletedAt, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			h.Error(httperror.NewInvalidParams(fmt.Sprintf("invalid deleted_at: %s", v)), w, r, "RestoreTrashHandler")
+			return
+		}
+	}
+
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "RestoreTrashHandler")
+		return
+	}
+
+	f, err := h.file.RestoreFromTrash(sp.StorageName, sp.IsPermanent, fileName, deletedAt)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError(fmt.Sprintf("file %s is not in trash", fileName)), w, r, "RestoreTrashHandler")
+		case httperror.CodeAlreadyExist:
+			h.Error(httperror.NewAlreadyExistError(fmt.Sprintf("file %s already exists", fileName)), w, r, "RestoreTrashHandler")
+		default:
+			h.Error(httperror.NewInternalError(fmt.Sprintf("unable to restore file: %s", fileName)).WithError(err), w, r, "RestoreTrashHandler")
+		}
+		return
+	}
+
+	go func() {
+		h.filePub.Publish(context.Background(), &event.FileEvent{
+			UserID:      sp.UserID,
+			UserName:    sp.StorageName,
+			FileName:    fileName,
+			Time:        time.Now().Unix(),
+			Size:        f.GetSize(),
+			Action:      event.Action_Restore,
+			IsPermanent: sp.IsPermanent,
+			Version:     f.GetVersion(),
+		})
+	}()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// EmptyTrashHandler removes all files from storage trash permanently
+func (h *Handler) EmptyTrashHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "EmptyTrashHandler")
+		return
+	}
+
+	removed, err := h.file.EmptyTrash(sp.StorageName, sp.IsPermanent)
+	if err != nil {
+		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to empty trash for storage: %s", sp.StorageName)).WithError(err), w, r, "EmptyTrashHandler")
+		return
+	}
+
+	type JSONEmpty struct {
+		Removed int64 `json:"removed"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(JSONEmpty{Removed: removed}); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "EmptyTrashHandler")
+		return
+	}
+}
@@ -2,60 +2,283 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/Mikhalevich/filesharing/internal/names"
+	"github.com/Mikhalevich/filesharing/pkg/conflict"
 	"github.com/Mikhalevich/filesharing/pkg/httperror"
 	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
 )
 
-// UploadHandler upload file to storage
-func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
-	sp, err := h.requestParameters(r)
+var (
+	errRequestTooLarge = errors.New("request size limit exceeded")
+	errFileTooLarge    = errors.New("file size limit exceeded")
+)
+
+// limitedReader fails with err when more than limit bytes read, zero limit means no limit
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+	err   error
+}
+
+// Read never returns data beyond limit, one extra byte is read to detect exceeding
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.limit <= 0 {
+		return lr.r.Read(p)
+	}
+
+	if lr.read > lr.limit {
+		return 0, lr.err
+	}
+
+	if rest := lr.limit - lr.read + 1; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.read > lr.limit {
+		return n - 1, lr.err
+	}
+	return n, err
+}
+
+func uploadError(fileName string, err error) *httperror.Error {
+	if errorCode(err) == httperror.CodeAlreadyExist {
+		return httperror.NewAlreadyExistError(fmt.Sprintf("file %s already exists", fileName)).WithError(err)
+	}
+
+	switch {
+	case errors.Is(err, errRequestTooLarge):
+		return httperror.NewTooLarge("request is too large").WithError(err)
+	case errors.Is(err, errFileTooLarge):
+		return httperror.NewTooLarge(fmt.Sprintf("file %s is too large", fileName)).WithError(err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return httperror.NewInvalidParams(fmt.Sprintf("file %s is truncated", fileName)).WithError(err)
+	}
+	return httperror.NewInternalError(fmt.Sprintf("unable to store file %s", fileName)).WithError(fmt.Errorf("upload: %w", err))
+}
+
+// uploadFile uploads file and publishes event with the final stored name
+func (h *Handler) uploadFile(sp storageParameters, ttl time.Duration, policy file.ConflictPolicy, fileName string, r io.Reader) (*file.File, *httperror.Error) {
+	f, err := h.file.Upload(sp.StorageName, sp.IsPermanent, fileName, ttl, policy, r)
 	if err != nil {
-		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, "UploadHandler")
-		return
+		return nil, uploadError(fileName, err)
 	}
 
-	mr, err := r.MultipartReader()
+	if f.GetName() == "" {
+		f.Name = fileName
+	}
+
+	go func() {
+		h.filePub.Publish(context.Background(), &event.FileEvent{
+			UserID:      sp.UserID,
+			UserName:    sp.StorageName,
+			FileName:    f.GetName(),
+			Time:        time.Now().Unix(),
+			Size:        f.GetSize(),
+			Action:      event.Action_Add,
+			IsPermanent: sp.IsPermanent,
+			Version:     f.GetVersion(),
+		})
+	}()
+
+	return f, nil
+}
+
+// spool stores part into temporary file, so next part can be read while this one is uploading
+func spool(r io.Reader) (*os.File, error) {
+	tmp, err := os.CreateTemp("", "filesharing-upload-*")
 	if err != nil {
-		h.Error(httperror.NewInternalError("request data error").WithError(fmt.Errorf("multipart reader: %w", err)), w, "UploadHandler")
-		return
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		closeSpool(tmp)
+		return nil, err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		closeSpool(tmp)
+		return nil, fmt.Errorf("seek temp file: %w", err)
+	}
+
+	return tmp, nil
+}
+
+func closeSpool(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// uploadParts uploads files from multipart form in the order of parts
+// with concurrency greater than one parts are spooled to disk and uploaded in parallel
+func (h *Handler) uploadParts(mr *multipart.Reader, sp storageParameters, ttl time.Duration, policy file.ConflictPolicy) ([]*file.File, *httperror.Error) {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		files     []*file.File
+		uploadErr *httperror.Error
+	)
+
+	fail := func(err *httperror.Error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if uploadErr == nil {
+			uploadErr = err
+		}
+	}
+
+	setResult := func(idx int, f *file.File, err *httperror.Error) {
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		files[idx] = f
 	}
 
-	for {
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return uploadErr != nil
+	}
+
+	sem := make(chan struct{}, h.cfg.Upload.Concurrency)
+	for !failed() {
 		part, err := mr.NextPart()
 		if errors.Is(err, io.EOF) {
 			break
 		} else if err != nil {
-			h.Error(httperror.NewInternalError("request data error").WithError(fmt.Errorf("next part: %w", err)), w, "UploadHandler")
-			return
+			if errors.Is(err, errRequestTooLarge) {
+				fail(uploadError("", err))
+			} else {
+				fail(httperror.NewInternalError("request data error").WithError(fmt.Errorf("next part: %w", err)))
+			}
+			break
 		}
 
-		fileName := part.FileName()
-		if fileName == "" {
+		if part.FileName() == "" {
 			continue
 		}
 
-		_, err = h.file.Upload(sp.StorageName, sp.IsPermanent, fileName, part)
+		fileName, err := names.NormalizeFile(part.FileName())
 		if err != nil {
-			h.Error(httperror.NewInternalError(fmt.Sprintf("unable to store file %s", fileName)).WithError(fmt.Errorf("upload: %w", err)), w, "UploadHandler")
-			return
+			fail(httperror.NewInvalidParams(fmt.Sprintf("invalid file name %q", part.FileName())).WithError(err))
+			break
+		}
+
+		mu.Lock()
+		idx := len(files)
+		files = append(files, nil)
+		mu.Unlock()
+
+		content := &limitedReader{
+			r:     part,
+			limit: h.cfg.Upload.MaxFileSize,
+			err:   errFileTooLarge,
+		}
+
+		if h.cfg.Upload.Concurrency == 1 {
+			f, err := h.uploadFile(sp, ttl, policy, fileName, content)
+			setResult(idx, f, err)
+			continue
+		}
+
+		sem <- struct{}{}
+		tmp, err := spool(content)
+		if err != nil {
+			<-sem
+			setResult(idx, nil, uploadError(fileName, err))
+			break
 		}
 
+		wg.Add(1)
 		go func() {
-			h.filePub.Publish(context.Background(), &event.FileEvent{
-				UserID:   sp.UserID,
-				UserName: sp.StorageName,
-				FileName: fileName,
-				Time:     time.Now().Unix(),
-				Action:   event.Action_Add,
-			})
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer closeSpool(tmp)
+
+			f, err := h.uploadFile(sp, ttl, policy, fileName, tmp)
+			setResult(idx, f, err)
 		}()
 	}
 
-	w.WriteHeader(http.StatusOK)
+	wg.Wait()
+
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+
+	return files, nil
+}
+
+// UploadHandler upload files from multipart form to storage
+// request parameters are taken from query because multipart body is streamed
+// conflict is one of overwrite, rename or fail
+// returns json encoded list of stored files
+func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "UploadHandler")
+		return
+	}
+
+	ttl, err := h.uploadTTL(sp, r.URL.Query().Get("ttl"))
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid ttl").WithError(err), w, r, "UploadHandler")
+		return
+	}
+
+	policy, err := conflict.ParsePolicy(r.URL.Query().Get("conflict"))
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("invalid conflict").WithError(err), w, r, "UploadHandler")
+		return
+	}
+
+	if max := h.cfg.Upload.MaxRequestSize; max > 0 {
+		if r.ContentLength > max {
+			h.Error(httperror.NewTooLarge(fmt.Sprintf("request is too large, max %d bytes", max)), w, r, "UploadHandler")
+			return
+		}
+
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{
+			Reader: &limitedReader{r: r.Body, limit: max, err: errRequestTooLarge},
+			Closer: r.Body,
+		}
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		h.Error(httperror.NewInternalError("request data error").WithError(fmt.Errorf("multipart reader: %w", err)), w, r, "UploadHandler")
+		return
+	}
+
+	files, uploadErr := h.uploadParts(mr, sp, ttl, policy)
+	if uploadErr != nil {
+		h.Error(uploadErr, w, r, "UploadHandler")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(makeFileInfo(files)); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "UploadHandler")
+		return
+	}
 }
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+)
+
+type versionParameters struct {
+	storageParameters
+	Version int64
+}
+
+// versionParameters returns storage parameters for version routes
+// versions exist in permanent storage only
+func (h *Handler) versionParameters(r *http.Request, versionRequired bool) (versionParameters, error) {
+	sp, err := h.requestParameters(r)
+	if err != nil {
+		return versionParameters{}, err
+	}
+
+	if sp.FileName == "" {
+		return versionParameters{}, fmt.Errorf("file name was not set")
+	}
+
+	vp := versionParameters{
+		storageParameters: sp,
+	}
+	vp.IsPermanent = true
+
+	v := r.FormValue("version")
+	if v == "" {
+		if versionRequired {
+			return versionParameters{}, fmt.Errorf("version was not set")
+		}
+		return vp, nil
+	}
+
+	vp.Version, err = strconv.ParseInt(v, 10, 64)
+	if err != nil || vp.Version <= 0 {
+		return versionParameters{}, fmt.Errorf("invalid version: %s", v)
+	}
+
+	return vp, nil
+}
+
+// GetVersionListHandler returns json encoded versions of file
+func (h *Handler) GetVersionListHandler(w http.ResponseWriter, r *http.Request) {
+	vp, err := h.versionParameters(r, false)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "GetVersionListHandler")
+		return
+	}
+
+	versions, err := h.file.Versions(vp.StorageName, vp.FileName)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError(fmt.Sprintf("file %s doesn't exist", vp.FileName)), w, r, "GetVersionListHandler")
+		default:
+			h.Error(httperror.NewInternalError(fmt.Sprintf("unable to get versions for file: %s", vp.FileName)).WithError(err), w, r, "GetVersionListHandler")
+		}
+		return
+	}
+
+	type JSONVersion struct {
+		Version int64 `json:"version"`
+		Size    int64 `json:"size"`
+		ModTime int64 `json:"mod_time"`
+	}
+	info := make([]JSONVersion, 0, len(versions))
+	for _, v := range versions {
+		info = append(info, JSONVersion{
+			Version: v.Version,
+			Size:    v.Size,
+			ModTime: v.ModTime,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "GetVersionListHandler")
+		return
+	}
+}
+
+// GetVersionHandler get specific file version from permanent storage
+func (h *Handler) GetVersionHandler(w http.ResponseWriter, r *http.Request) {
+	vp, err := h.versionParameters(r, true)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "GetVersionHandler")
+		return
+	}
+
+	err = writeFile(w, r, vp.FileName, func(w io.Writer) error {
+		return h.file.GetVersion(vp.StorageName, vp.FileName, vp.Version, w)
+	})
+	if err != nil {
+		h.Error(httperror.NewInternalError("can't open file version").WithError(err), w, r, "GetVersionHandler")
+		return
+	}
+}
+
+// RestoreVersionHandler makes specified version the latest one
+func (h *Handler) RestoreVersionHandler(w http.ResponseWriter, r *http.Request) {
+	vp, err := h.versionParameters(r, true)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "RestoreVersionHandler")
+		return
+	}
+
+	f, err := h.file.RestoreVersion(vp.StorageName, vp.FileName, vp.Version)
+	if err != nil {
+		switch errorCode(err) {
+		case httperror.CodeNotExist:
+			h.Error(httperror.NewNotExistError(fmt.Sprintf("version %d of file %s doesn't exist", vp.Version, vp.FileName)), w, r, "RestoreVersionHandler")
+		default:
+			h.Error(httperror.NewInternalError(fmt.Sprintf("unable to restore version %d of file: %s", vp.Version, vp.FileName)).WithError(err), w, r, "RestoreVersionHandler")
+		}
+		return
+	}
+
+	go func() {
+		h.filePub.Publish(context.Background(), &event.FileEvent{
+			UserID:      vp.UserID,
+			UserName:    vp.StorageName,
+			FileName:    vp.FileName,
+			Time:        time.Now().Unix(),
+			Size:        f.GetSize(),
+			Action:      event.Action_VersionRestore,
+			IsPermanent: true,
+			Version:     vp.Version,
+		})
+	}()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PruneVersionsHandler removes old file versions by count or age
+// configured policy is used for omitted parameters
+func (h *Handler) PruneVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	vp, err := h.versionParameters(r, false)
+	if err != nil {
+		h.Error(httperror.NewInvalidParams("request params").WithError(err), w, r, "PruneVersionsHandler")
+		return
+	}
+
+	keep := h.cfg.Versions.Keep
+	if v := r.FormValue("keep"); v != "" {
+		keep, err = strconv.Atoi(v)
+		if err != nil || keep < 1 {
+			h.Error(httperror.NewInvalidParams(fmt.Sprintf("invalid keep: %s", v)), w, r, "PruneVersionsHandler")
+			return
+		}
+	}
+
+	maxAge := time.Duration(h.cfg.Versions.MaxAge) * time.Second
+	if v := r.FormValue("max_age"); v != "" {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds < 0 {
+			h.Error(httperror.NewInvalidParams(fmt.Sprintf("invalid max_age: %s", v)), w, r, "PruneVersionsHandler")
+			return
+		}
+		maxAge = time.Duration(seconds) * time.Second
+	}
+
+	removed, err := h.file.PruneVersions(vp.StorageName, vp.FileName, keep, maxAge)
+	if err != nil {
+		h.Error(httperror.NewInternalError(fmt.Sprintf("unable to prune versions of file: %s", vp.FileName)).WithError(err), w, r, "PruneVersionsHandler")
+		return
+	}
+
+	if removed > 0 {
+		go func() {
+			h.filePub.Publish(context.Background(), &event.FileEvent{
+				UserID:      vp.UserID,
+				UserName:    vp.StorageName,
+				FileName:    vp.FileName,
+				Time:        time.Now().Unix(),
+				Action:      event.Action_VersionPrune,
+				IsPermanent: true,
+			})
+		}()
+	}
+
+	type JSONPrune struct {
+		Removed int64 `json:"removed"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(JSONPrune{Removed: removed}); err != nil {
+		h.Error(httperror.NewInternalError("json encoder error").WithError(err), w, r, "PruneVersionsHandler")
+		return
+	}
+}
@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSender drops messages into directory instead of delivering them
+// it's intended for local testing
+type FileSender struct {
+	dir string
+}
+
+// NewFileSender constructor for FileSender
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create mail directory: %w", err)
+	}
+
+	return &FileSender{
+		dir: dir,
+	}, nil
+}
+
+func (fs *FileSender) Send(m Message) error {
+	to := strings.NewReplacer("/", "_", "\\", "_", "@", "_at_").Replace(m.To)
+	name := filepath.Join(fs.dir, fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), to))
+
+	if err := os.WriteFile(name, m.bytes(), 0600); err != nil {
+		return fmt.Errorf("write mail file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package mail
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message represents single mail message
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers mail messages
+type Sender interface {
+	Send(m Message) error
+}
+
+// Mailer composes gateway notifications and sends them through Sender
+type Mailer struct {
+	sender   Sender
+	from     string
+	resetURL string
+}
+
+// NewMailer constructor for Mailer
+func NewMailer(s Sender, from string, resetURL string) *Mailer {
+	return &Mailer{
+		sender:   s,
+		from:     from,
+		resetURL: resetURL,
+	}
+}
+
+// SendPasswordReset sends password reset token for storage
+func (m *Mailer) SendPasswordReset(to string, name string, token string, expiresAt time.Time) error {
+	body := fmt.Sprintf("Password reset was requested for storage %s.\n\nReset token: %s\n", name, token)
+
+	if m.resetURL != "" {
+		u, err := url.Parse(m.resetURL)
+		if err != nil {
+			return fmt.Errorf("parse reset url: %w", err)
+		}
+
+		q := u.Query()
+		q.Set("storage", name)
+		q.Set("token", token)
+		u.RawQuery = q.Encode()
+
+		body += fmt.Sprintf("Reset link: %s\n", u.String())
+	}
+
+	body += fmt.Sprintf("\nToken expires at %s.\nIf you didn't request password reset just ignore this message.\n", expiresAt.UTC().Format(time.RFC1123))
+
+	return m.sender.Send(Message{
+		From:    m.from,
+		To:      to,
+		Subject: fmt.Sprintf("Password reset for %s", name),
+		Body:    body,
+	})
+}
+
+// Config mail configuration
+type Config struct {
+	Sender    string     `yaml:"sender"`
+	From      string     `yaml:"from"`
+	ResetURL  string     `yaml:"reset_url"`
+	Directory string     `yaml:"directory"`
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+// DefaultConfig used for omitted configuration values
+// messages are written to files, so gateway starts without mail server
+func DefaultConfig() Config {
+	return Config{
+		Sender:    "file",
+		From:      "noreply@filesharing.local",
+		Directory: filepath.Join(os.TempDir(), "filesharing", "mail"),
+	}
+}
+
+func (c Config) Validate() error {
+	switch c.Sender {
+	case "file":
+		if c.Directory == "" {
+			return fmt.Errorf("directory is required for file sender")
+		}
+	case "smtp":
+		if c.SMTP.Host == "" {
+			return fmt.Errorf("smtp host is required for smtp sender")
+		}
+	default:
+		return fmt.Errorf("unknown sender: %q", c.Sender)
+	}
+
+	if c.From == "" {
+		return fmt.Errorf("from is required")
+	}
+
+	return nil
+}
+
+// NewSender creates sender specified in config
+func NewSender(c Config) (Sender, error) {
+	switch c.Sender {
+	case "file":
+		return NewFileSender(c.Directory)
+	case "smtp":
+		return NewSMTPSender(c.SMTP), nil
+	}
+
+	return nil, fmt.Errorf("unknown sender: %q", c.Sender)
+}
+
+var headerReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+func (m Message) bytes() []byte {
+	return []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		headerReplacer.Replace(m.From),
+		headerReplacer.Replace(m.To),
+		headerReplacer.Replace(m.Subject),
+		time.Now().Format(time.RFC1123Z),
+		m.Body))
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// SMTPSender delivers messages through smtp server
+type SMTPSender struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPSender constructor for SMTPSender
+func NewSMTPSender(c SMTPConfig) *SMTPSender {
+	return &SMTPSender{
+		cfg: c,
+	}
+}
+
+func (s *SMTPSender) Send(m Message) error {
+	var auth smtp.Auth
+	if s.cfg.User != "" {
+		auth = smtp.PlainAuth("", s.cfg.User, s.cfg.Password, s.cfg.Host)
+	}
+
+	port := s.cfg.Port
+	if port == 0 {
+		port = 25
+	}
+
+	if err := smtp.SendMail(fmt.Sprintf("%s:%d", s.cfg.Host, port), auth, m.From, []string{m.To}, m.bytes()); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+
+	return nil
+}
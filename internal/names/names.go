@@ -0,0 +1,119 @@
+package names
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxFileNameLen max file name length in bytes, most file systems limit name to 255 bytes
+	MaxFileNameLen = 255
+	// MaxStorageNameLen max storage name length in bytes
+	MaxStorageNameLen = 64
+)
+
+var (
+	ErrEmpty    = errors.New("name is empty")
+	ErrTooLong  = errors.New("name is too long")
+	ErrInvalid  = errors.New("name contains invalid characters")
+	ErrReserved = errors.New("name is reserved")
+)
+
+// reserved device names of windows, they are reserved with any extension too
+var reserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func normalize(name string, maxLen int) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", ErrInvalid
+	}
+
+	name = norm.NFC.String(name)
+
+	if name == "" {
+		return "", ErrEmpty
+	}
+
+	if len(name) > maxLen {
+		return "", fmt.Errorf("%w: %d bytes, max %d", ErrTooLong, len(name), maxLen)
+	}
+
+	for _, r := range name {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return "", fmt.Errorf("%w: %q", ErrInvalid, r)
+		}
+	}
+
+	if name == "." || name == ".." {
+		return "", ErrReserved
+	}
+
+	// windows silently drops trailing dots and spaces so such names alias other files
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") || strings.HasPrefix(name, " ") {
+		return "", fmt.Errorf("%w: leading space or trailing dot or space", ErrInvalid)
+	}
+
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if reserved[strings.ToUpper(base)] {
+		return "", ErrReserved
+	}
+
+	return name, nil
+}
+
+// NormalizeFile validates file name and returns it in unicode NFC form
+func NormalizeFile(name string) (string, error) {
+	return normalize(name, MaxFileNameLen)
+}
+
+// NormalizeStorage validates storage name and returns it in unicode NFC form
+func NormalizeStorage(name string) (string, error) {
+	return normalize(name, MaxStorageNameLen)
+}
+
+// isAttrChar checks attr-char from RFC 5987
+func isAttrChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+// asciiFallback replaces characters unsafe for quoted filename parameter
+func asciiFallback(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' || r == '%' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ContentDisposition returns RFC 6266 header value with ascii fallback and utf-8 encoded file name
+func ContentDisposition(disposition string, name string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; isAttrChar(c) {
+			encoded.WriteByte(c)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+
+	return fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", disposition, asciiFallback(name), encoded.String())
+}
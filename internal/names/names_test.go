@@ -0,0 +1,99 @@
+package names
+
+import (
+	"mime"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func FuzzNormalizeFile(f *testing.F) {
+	for _, s := range []string{
+		"file.txt",
+		"Ãf́.txt",
+		"../etc/passwd",
+		"a\\b",
+		"con.txt",
+		"name.",
+		" name",
+		"\x00",
+		"\xff\xfe",
+		strings.Repeat("a", MaxFileNameLen+1),
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		normalized, err := NormalizeFile(name)
+		if err != nil {
+			return
+		}
+
+		if !utf8.ValidString(normalized) || !norm.NFC.IsNormalString(normalized) {
+			t.Fatalf("name %q is not valid nfc string", normalized)
+		}
+
+		if normalized == "" || len(normalized) > MaxFileNameLen {
+			t.Fatalf("invalid name length %d", len(normalized))
+		}
+
+		if normalized == "." || normalized == ".." {
+			t.Fatalf("reserved name %q accepted", normalized)
+		}
+
+		for _, r := range normalized {
+			if r == '/' || r == '\\' || unicode.IsControl(r) {
+				t.Fatalf("name %q contains %q", normalized, r)
+			}
+		}
+
+		again, err := NormalizeFile(normalized)
+		if err != nil || again != normalized {
+			t.Fatalf("normalization is not idempotent: %q -> %q, %v", normalized, again, err)
+		}
+	})
+}
+
+func FuzzContentDisposition(f *testing.F) {
+	for _, s := range []string{
+		"file.txt",
+		"отчет.pdf",
+		"a\"b.txt",
+		"a\\b;c=d.txt",
+		"100%.txt",
+		"line\r\nbreak",
+		"\xff",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		header := ContentDisposition("attachment", name)
+
+		for i := 0; i < len(header); i++ {
+			if header[i] < 0x20 || header[i] >= 0x7f {
+				t.Fatalf("header %q contains byte %#x", header, header[i])
+			}
+		}
+
+		if !utf8.ValidString(name) {
+			return
+		}
+
+		disposition, params, err := mime.ParseMediaType(header)
+		if err != nil {
+			t.Fatalf("unable to parse header %q: %v", header, err)
+		}
+
+		if disposition != "attachment" {
+			t.Fatalf("unexpected disposition %q", disposition)
+		}
+
+		if params["filename"] != name {
+			t.Fatalf("file name %q decoded as %q from %q", name, params["filename"], header)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package preview
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Preview generated thumbnail or text snippet
+type Preview struct {
+	ContentType string
+	Data        []byte
+}
+
+// DirCache keeps previews on disk grouped by storage and file
+// so all previews of file are invalidated at once
+type DirCache struct {
+	root string
+}
+
+// NewDirCache constructor for DirCache
+func NewDirCache(root string) *DirCache {
+	return &DirCache{
+		root: root,
+	}
+}
+
+func hashName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+func folderName(permanent bool) string {
+	if permanent {
+		return "permanent"
+	}
+	return "temporary"
+}
+
+func (c *DirCache) storagePath(storage string) string {
+	return filepath.Join(c.root, hashName(storage))
+}
+
+func (c *DirCache) filePath(storage string, permanent bool, name string) string {
+	return filepath.Join(c.storagePath(storage), folderName(permanent), hashName(name))
+}
+
+// Get returns cached preview, false if it's missing
+func (c *DirCache) Get(storage string, permanent bool, name string, variant string) (*Preview, bool, error) {
+	dir := c.filePath(storage, permanent, name)
+
+	contentType, err := os.ReadFile(filepath.Join(dir, variant+".type"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, variant))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return &Preview{
+		ContentType: string(contentType),
+		Data:        data,
+	}, true, nil
+}
+
+// Put stores preview, files are renamed into place so readers never see partial data
+func (c *DirCache) Put(storage string, permanent bool, name string, variant string, p *Preview) error {
+	dir := c.filePath(storage, permanent, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+
+	if err := writeFile(filepath.Join(dir, variant), p.Data); err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(dir, variant+".type"), []byte(p.ContentType))
+}
+
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Remove drops all previews of file
+func (c *DirCache) Remove(storage string, permanent bool, name string) error {
+	return os.RemoveAll(c.filePath(storage, permanent, name))
+}
+
+// RemoveStorage drops all previews of storage
+func (c *DirCache) RemoveStorage(storage string) error {
+	return os.RemoveAll(c.storagePath(storage))
+}
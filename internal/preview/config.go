@@ -0,0 +1,58 @@
+package preview
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config preview generation configuration
+// sizes are allowed thumbnail sizes in pixels, requested size is rounded up to the nearest one
+type Config struct {
+	Directory   string `yaml:"directory"`
+	Sizes       []int  `yaml:"sizes"`
+	MaxFileSize int64  `yaml:"max_file_size"`
+	MaxPixels   int    `yaml:"max_pixels"`
+	SnippetSize int    `yaml:"snippet_size"`
+}
+
+// DefaultConfig used for omitted configuration values
+func DefaultConfig() Config {
+	return Config{
+		Directory:   filepath.Join(os.TempDir(), "filesharing", "preview"),
+		Sizes:       []int{64, 128, 256, 512},
+		MaxFileSize: 20 << 20,
+		MaxPixels:   50 * 1000 * 1000,
+		SnippetSize: 1024,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Directory == "" {
+		return fmt.Errorf("directory is required")
+	}
+
+	if len(c.Sizes) == 0 {
+		return fmt.Errorf("at least one size is required")
+	}
+
+	for _, s := range c.Sizes {
+		if s <= 0 {
+			return fmt.Errorf("invalid size: %d", s)
+		}
+	}
+
+	if c.MaxFileSize <= 0 {
+		return fmt.Errorf("invalid max file size: %d", c.MaxFileSize)
+	}
+
+	if c.MaxPixels <= 0 {
+		return fmt.Errorf("invalid max pixels: %d", c.MaxPixels)
+	}
+
+	if c.SnippetSize <= 0 {
+		return fmt.Errorf("invalid snippet size: %d", c.SnippetSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,334 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"github.com/Mikhalevich/filesharing/internal/search"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+)
+
+var (
+	ErrNotSupported = errors.New("preview is not supported for file type")
+	ErrTooLarge     = errors.New("file is too large for preview")
+)
+
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+const snippetVariant = "snippet"
+
+type Filer interface {
+	Get(storage string, isPermanent bool, fileName string, w io.Writer) error
+}
+
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Generator creates previews on file events and serves them from cache
+type Generator struct {
+	cache  *DirCache
+	file   Filer
+	logger Logger
+	cfg    Config
+}
+
+// NewGenerator constructor for Generator
+func NewGenerator(c *DirCache, f Filer, l Logger, cfg Config) *Generator {
+	sizes := append([]int(nil), cfg.Sizes...)
+	sort.Ints(sizes)
+	cfg.Sizes = sizes
+
+	return &Generator{
+		cache:  c,
+		file:   f,
+		logger: l,
+		cfg:    cfg,
+	}
+}
+
+func isImage(name string) bool {
+	return imageExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// thumbnailSize rounds requested size up to the nearest allowed size
+// zero size means the smallest one
+func (g *Generator) thumbnailSize(requested int) int {
+	for _, s := range g.cfg.Sizes {
+		if s >= requested {
+			return s
+		}
+	}
+	return g.cfg.Sizes[len(g.cfg.Sizes)-1]
+}
+
+// limitedWriter fails when more than limit bytes written
+type limitedWriter struct {
+	buf   bytes.Buffer
+	limit int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if int64(lw.buf.Len()+len(p)) > lw.limit {
+		return 0, ErrTooLarge
+	}
+	return lw.buf.Write(p)
+}
+
+// headWriter keeps first limit bytes and stops download after that
+type headWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+var errHeadFull = errors.New("head is full")
+
+func (hw *headWriter) Write(p []byte) (int, error) {
+	if rest := hw.limit - hw.buf.Len(); len(p) >= rest {
+		hw.buf.Write(p[:rest])
+		return rest, errHeadFull
+	}
+	return hw.buf.Write(p)
+}
+
+func (g *Generator) snippet(storage string, permanent bool, name string) (*Preview, error) {
+	hw := headWriter{limit: g.cfg.SnippetSize}
+	if err := g.file.Get(storage, permanent, name, &hw); err != nil && !errors.Is(err, errHeadFull) {
+		return nil, fmt.Errorf("get file %s: %w", name, err)
+	}
+
+	content := hw.buf.Bytes()
+	// cut partial rune at the end of snippet
+	for i := 0; i < utf8.UTFMax && len(content) > 0 && !utf8.Valid(content); i++ {
+		content = content[:len(content)-1]
+	}
+
+	if !utf8.Valid(content) {
+		return nil, ErrNotSupported
+	}
+
+	return &Preview{
+		ContentType: "text/plain; charset=utf-8",
+		Data:        content,
+	}, nil
+}
+
+func (g *Generator) decode(storage string, permanent bool, name string) (image.Image, string, error) {
+	lw := limitedWriter{limit: g.cfg.MaxFileSize}
+	if err := g.file.Get(storage, permanent, name, &lw); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return nil, "", ErrTooLarge
+		}
+		return nil, "", fmt.Errorf("get file %s: %w", name, err)
+	}
+
+	// check dimensions before decoding to avoid decompression bombs
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(lw.buf.Bytes()))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+
+	if cfg.Width*cfg.Height > g.cfg.MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(lw.buf.Bytes()))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+
+	return img, format, nil
+}
+
+func scale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	if w >= h {
+		h = h * size / w
+		w = size
+	} else {
+		w = w * size / h
+		h = size
+	}
+
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, b, xdraw.Src, nil)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// encode keeps transparency with png, opaque thumbnails are encoded as jpeg
+func encode(img image.Image, format string) (*Preview, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" || isOpaque(img) {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, fmt.Errorf("encode jpeg: %w", err)
+		}
+		return &Preview{ContentType: "image/jpeg", Data: buf.Bytes()}, nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+	return &Preview{ContentType: "image/png", Data: buf.Bytes()}, nil
+}
+
+func (g *Generator) thumbnails(storage string, permanent bool, name string, sizes []int) (map[int]*Preview, error) {
+	img, format, err := g.decode(storage, permanent, name)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make(map[int]*Preview, len(sizes))
+	for _, s := range sizes {
+		p, err := encode(scale(img, s), format)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := g.cache.Put(storage, permanent, name, strconv.Itoa(s), p); err != nil {
+			return nil, fmt.Errorf("cache thumbnail: %w", err)
+		}
+		previews[s] = p
+	}
+
+	return previews, nil
+}
+
+// Preview returns thumbnail for images and text snippet for text files
+// missing previews are generated and cached
+func (g *Generator) Preview(storage string, permanent bool, name string, size int) (*Preview, error) {
+	variant := snippetVariant
+	if isImage(name) {
+		size = g.thumbnailSize(size)
+		variant = strconv.Itoa(size)
+	} else if !search.IsText(name) {
+		return nil, ErrNotSupported
+	}
+
+	p, ok, err := g.cache.Get(storage, permanent, name, variant)
+	if err != nil {
+		g.logger.Errorf("unable to read cached preview for %s: %v", name, err)
+	} else if ok {
+		return p, nil
+	}
+
+	if variant == snippetVariant {
+		p, err = g.snippet(storage, permanent, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := g.cache.Put(storage, permanent, name, variant, p); err != nil {
+			g.logger.Errorf("unable to cache snippet for %s: %v", name, err)
+		}
+		return p, nil
+	}
+
+	previews, err := g.thumbnails(storage, permanent, name, []int{size})
+	if err != nil {
+		return nil, err
+	}
+
+	return previews[size], nil
+}
+
+// Generate replaces cached previews of file with fresh ones
+func (g *Generator) Generate(storage string, permanent bool, name string) error {
+	if err := g.cache.Remove(storage, permanent, name); err != nil {
+		return fmt.Errorf("invalidate previews: %w", err)
+	}
+
+	if isImage(name) {
+		_, err := g.thumbnails(storage, permanent, name, g.cfg.Sizes)
+		return err
+	}
+
+	if search.IsText(name) {
+		p, err := g.snippet(storage, permanent, name)
+		if err != nil {
+			return err
+		}
+		return g.cache.Put(storage, permanent, name, snippetVariant, p)
+	}
+
+	return nil
+}
+
+// HandleFileEvent event.FileEvent subscriber
+func (g *Generator) HandleFileEvent(ctx context.Context, e *event.FileEvent) error {
+	switch e.GetAction() {
+	case event.Action_Add, event.Action_Restore, event.Action_VersionRestore:
+		if e.GetSize() > g.cfg.MaxFileSize {
+			if err := g.cache.Remove(e.GetUserName(), e.GetIsPermanent(), e.GetFileName()); err != nil {
+				g.logger.Errorf("unable to invalidate previews for %s: %v", e.GetFileName(), err)
+			}
+			return nil
+		}
+
+		if err := g.Generate(e.GetUserName(), e.GetIsPermanent(), e.GetFileName()); err != nil {
+			if errors.Is(err, ErrNotSupported) || errors.Is(err, ErrTooLarge) {
+				g.logger.Debugf("skip preview for %s: %v", e.GetFileName(), err)
+				return nil
+			}
+			g.logger.Errorf("unable to generate preview for %s: %v", e.GetFileName(), err)
+		}
+
+	case event.Action_Remove, event.Action_Expired:
+		if err := g.cache.Remove(e.GetUserName(), e.GetIsPermanent(), e.GetFileName()); err != nil {
+			g.logger.Errorf("unable to invalidate previews for %s: %v", e.GetFileName(), err)
+		}
+	}
+
+	return nil
+}
+
+// HandleAccountEvent event.AccountEvent subscriber
+func (g *Generator) HandleAccountEvent(ctx context.Context, e *event.AccountEvent) error {
+	if e.GetAction() == event.AccountAction_Deleted {
+		if err := g.cache.RemoveStorage(e.GetUserName()); err != nil {
+			g.logger.Errorf("unable to remove previews for %s: %v", e.GetUserName(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"fmt"
+)
+
+// Bucket describes token bucket parameters
+// rate is amount of tokens per second, zero rate disables bucket
+type Bucket struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Lockout describes progressive lockout on failed login attempts
+// periods are in seconds, zero attempts disables lockout
+type Lockout struct {
+	Attempts   int `yaml:"attempts"`
+	Period     int `yaml:"period"`
+	MaxPeriod  int `yaml:"max_period"`
+	ResetAfter int `yaml:"reset_after"`
+}
+
+type Config struct {
+	IP           Bucket  `yaml:"ip"`
+	Storage      Bucket  `yaml:"storage"`
+	Lockout      Lockout `yaml:"lockout"`
+	RealIPHeader string  `yaml:"real_ip_header"`
+}
+
+func (b Bucket) enabled() bool {
+	return b.Rate > 0
+}
+
+func (b Bucket) validate() error {
+	if b.Rate < 0 {
+		return fmt.Errorf("invalid rate: %v", b.Rate)
+	}
+
+	if b.enabled() && b.Burst <= 0 {
+		return fmt.Errorf("invalid burst: %d", b.Burst)
+	}
+
+	return nil
+}
+
+func (l Lockout) enabled() bool {
+	return l.Attempts > 0
+}
+
+func (l Lockout) validate() error {
+	if l.Attempts < 0 {
+		return fmt.Errorf("invalid attempts: %d", l.Attempts)
+	}
+
+	if !l.enabled() {
+		return nil
+	}
+
+	if l.Period <= 0 {
+		return fmt.Errorf("invalid period: %d", l.Period)
+	}
+
+	if l.MaxPeriod < l.Period {
+		return fmt.Errorf("max period %d is less than period %d", l.MaxPeriod, l.Period)
+	}
+
+	if l.ResetAfter <= 0 {
+		return fmt.Errorf("invalid reset after: %d", l.ResetAfter)
+	}
+
+	return nil
+}
+
+func (c Config) Validate() error {
+	if err := c.IP.validate(); err != nil {
+		return fmt.Errorf("ip: %w", err)
+	}
+
+	if err := c.Storage.validate(); err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+
+	if err := c.Lockout.validate(); err != nil {
+		return fmt.Errorf("lockout: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter limits requests per client ip and per storage name
+// and locks out clients after repeated failed login attempts
+type Limiter struct {
+	mu    sync.RWMutex
+	cfg   Config
+	store Store
+}
+
+// New constructor for Limiter
+func New(cfg Config, s Store) *Limiter {
+	return &Limiter{
+		cfg:   cfg,
+		store: s,
+	}
+}
+
+// SetConfig replaces limits, state of buckets and lockouts is kept
+func (l *Limiter) SetConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+}
+
+func (l *Limiter) config() Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cfg
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func storageKey(storage string) string {
+	return "storage:" + storage
+}
+
+// lockout keys differ from bucket ones, so both states can be kept in one shared store
+// storage is separated by slash, it's never a part of ip address unlike colon
+func ipLockoutKey(ip string) string {
+	return "lockout:" + ip
+}
+
+func lockoutKey(ip, storage string) string {
+	return "lockout:" + ip + "/" + storage
+}
+
+// lockoutKeys client is locked out for the storage and for all storages after too many failures,
+// lockout is never keyed on storage alone, so nobody is able to lock out storage owner
+func (l *Limiter) lockoutKeys(ip, storage string) []string {
+	if ip == "" {
+		return nil
+	}
+
+	keys := []string{ipLockoutKey(ip)}
+	if storage != "" {
+		keys = append(keys, lockoutKey(ip, storage))
+	}
+
+	return keys
+}
+
+// Allow takes token from ip and storage buckets
+// returns zero duration if request is allowed or time to wait before retry
+func (l *Limiter) Allow(ip, storage string) (time.Duration, error) {
+	cfg := l.config()
+
+	if cfg.IP.enabled() && ip != "" {
+		wait, err := l.store.Take(ipKey(ip), cfg.IP)
+		if err != nil {
+			return 0, fmt.Errorf("take ip token: %w", err)
+		}
+
+		if wait > 0 {
+			return wait, nil
+		}
+	}
+
+	if cfg.Storage.enabled() && storage != "" {
+		wait, err := l.store.Take(storageKey(storage), cfg.Storage)
+		if err != nil {
+			return 0, fmt.Errorf("take storage token: %w", err)
+		}
+
+		if wait > 0 {
+			return wait, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// Locked returns remaining lockout period for ip or ip and storage pair
+func (l *Limiter) Locked(ip, storage string) (time.Duration, error) {
+	cfg := l.config()
+
+	if !cfg.Lockout.enabled() {
+		return 0, nil
+	}
+
+	var locked time.Duration
+	for _, key := range l.lockoutKeys(ip, storage) {
+		d, err := l.store.Locked(key)
+		if err != nil {
+			return 0, fmt.Errorf("locked %s: %w", key, err)
+		}
+
+		if d > locked {
+			locked = d
+		}
+	}
+
+	return locked, nil
+}
+
+// Fail registers failed login attempt
+// returns lockout period if amount of attempts exceeded
+func (l *Limiter) Fail(ip, storage string) (time.Duration, error) {
+	cfg := l.config()
+
+	if !cfg.Lockout.enabled() {
+		return 0, nil
+	}
+
+	var locked time.Duration
+	for _, key := range l.lockoutKeys(ip, storage) {
+		count, err := l.store.Fail(key, time.Duration(cfg.Lockout.ResetAfter)*time.Second)
+		if err != nil {
+			return 0, fmt.Errorf("fail %s: %w", key, err)
+		}
+
+		period := l.lockoutPeriod(count)
+		if period <= 0 {
+			continue
+		}
+
+		if err := l.store.Lock(key, period); err != nil {
+			return 0, fmt.Errorf("lock %s: %w", key, err)
+		}
+
+		if period > locked {
+			locked = period
+		}
+	}
+
+	return locked, nil
+}
+
+// Reset drops failed attempts after successful login
+func (l *Limiter) Reset(ip, storage string) error {
+	cfg := l.config()
+
+	if !cfg.Lockout.enabled() {
+		return nil
+	}
+
+	for _, key := range l.lockoutKeys(ip, storage) {
+		if err := l.store.Reset(key); err != nil {
+			return fmt.Errorf("reset %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// lockoutPeriod doubles lockout period for every failed attempt over the limit
+func (l *Limiter) lockoutPeriod(failures int) time.Duration {
+	cfg := l.config()
+
+	over := failures - cfg.Lockout.Attempts
+	if over < 0 {
+		return 0
+	}
+
+	period := time.Duration(cfg.Lockout.Period) * time.Second
+	maxPeriod := time.Duration(cfg.Lockout.MaxPeriod) * time.Second
+	for i := 0; i < over && period < maxPeriod; i++ {
+		period *= 2
+	}
+
+	if period > maxPeriod {
+		period = maxPeriod
+	}
+
+	return period
+}
+
+// ClientIP returns request client ip
+// configured real ip header is used when gateway is behind proxy
+func (l *Limiter) ClientIP(r *http.Request) string {
+	cfg := l.config()
+
+	if cfg.RealIPHeader != "" {
+		if v := r.Header.Get(cfg.RealIPHeader); v != "" {
+			return strings.TrimSpace(strings.Split(v, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
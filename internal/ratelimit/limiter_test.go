@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(cfg Config) (*Limiter, *clock) {
+	c := clock{now: time.Unix(1000, 0)}
+	s := NewMemoryStore()
+	s.now = c.Now
+	return New(cfg, s), &c
+}
+
+// keyStore records keys used for buckets and lockouts
+type keyStore struct {
+	*MemoryStore
+	buckets  map[string]bool
+	lockouts map[string]bool
+}
+
+func (s *keyStore) Take(key string, b Bucket) (time.Duration, error) {
+	s.buckets[key] = true
+	return s.MemoryStore.Take(key, b)
+}
+
+func (s *keyStore) Fail(key string, resetAfter time.Duration) (int, error) {
+	s.lockouts[key] = true
+	return s.MemoryStore.Fail(key, resetAfter)
+}
+
+func TestBucket(t *testing.T) {
+	l, c := newTestLimiter(Config{
+		IP:      Bucket{Rate: 1, Burst: 2},
+		Storage: Bucket{Rate: 0.5, Burst: 3},
+	})
+
+	for i := 0; i < 2; i++ {
+		if wait, _ := l.Allow("1.1.1.1", "alice"); wait != 0 {
+			t.Fatalf("request %d within burst is limited: %v", i, wait)
+		}
+	}
+
+	if wait, _ := l.Allow("1.1.1.1", "alice"); wait != time.Second {
+		t.Fatalf("expected one second wait for empty ip bucket, got %v", wait)
+	}
+
+	if wait, _ := l.Allow("2.2.2.2", "alice"); wait != 0 {
+		t.Fatalf("another ip is limited: %v", wait)
+	}
+
+	if wait, _ := l.Allow("3.3.3.3", "alice"); wait != 2*time.Second {
+		t.Fatalf("expected two seconds wait for empty storage bucket, got %v", wait)
+	}
+
+	c.advance(time.Second)
+	if wait, _ := l.Allow("1.1.1.1", ""); wait != 0 {
+		t.Fatalf("bucket is not refilled: %v", wait)
+	}
+}
+
+func TestProgressiveLockout(t *testing.T) {
+	l, c := newTestLimiter(Config{
+		Lockout: Lockout{Attempts: 2, Period: 10, MaxPeriod: 30, ResetAfter: 100},
+	})
+
+	tests := []time.Duration{0, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, expected := range tests {
+		locked, err := l.Fail("1.1.1.1", "alice")
+		if err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+
+		if locked != expected {
+			t.Fatalf("failure %d: expected lockout %v, got %v", i+1, expected, locked)
+		}
+	}
+
+	c.advance(5 * time.Second)
+	if locked, _ := l.Locked("1.1.1.1", "bob"); locked != 25*time.Second {
+		t.Fatalf("ip is not locked for other storages: %v", locked)
+	}
+
+	if locked, _ := l.Locked("2.2.2.2", "alice"); locked != 0 {
+		t.Fatalf("storage is locked for other ip: %v", locked)
+	}
+
+	if err := l.Reset("1.1.1.1", "alice"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if locked, _ := l.Locked("1.1.1.1", "alice"); locked != 0 {
+		t.Fatalf("lockout is not reset: %v", locked)
+	}
+
+	// failures are forgotten after reset_after without failures
+	l.Fail("1.1.1.1", "alice")
+	c.advance(101 * time.Second)
+	l.Fail("1.1.1.1", "alice")
+	if locked, _ := l.Fail("1.1.1.1", "alice"); locked != 10*time.Second {
+		t.Fatalf("expired failures are counted: %v", locked)
+	}
+}
+
+func TestLockoutKeysDifferFromBuckets(t *testing.T) {
+	s := keyStore{
+		MemoryStore: NewMemoryStore(),
+		buckets:     make(map[string]bool),
+		lockouts:    make(map[string]bool),
+	}
+
+	l := New(Config{
+		IP:      Bucket{Rate: 1, Burst: 1},
+		Storage: Bucket{Rate: 1, Burst: 1},
+		Lockout: Lockout{Attempts: 1, Period: 1, MaxPeriod: 1, ResetAfter: 1},
+	}, &s)
+
+	for _, ip := range []string{"1.1.1.1", "::1"} {
+		l.Allow(ip, "alice")
+		l.Fail(ip, "alice")
+	}
+
+	if len(s.lockouts) != 4 {
+		t.Fatalf("unexpected lockout keys: %v", s.lockouts)
+	}
+
+	for key := range s.lockouts {
+		if s.buckets[key] {
+			t.Fatalf("key %s is shared by bucket and lockout", key)
+		}
+	}
+}
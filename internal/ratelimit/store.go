@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps limiter state
+// in-memory store is used by default, shared implementation allows
+// several gateway instances to use the same limits
+type Store interface {
+	// Take removes single token from bucket identified by key
+	// returns zero duration on success or time to wait for the next token
+	Take(key string, b Bucket) (time.Duration, error)
+	// Fail registers failed attempt for key and returns amount of failures
+	// counter is dropped after resetAfter without failures
+	Fail(key string, resetAfter time.Duration) (int, error)
+	// Lock locks key for specified period
+	Lock(key string, period time.Duration) error
+	// Locked returns remaining lock period for key, zero if key is not locked
+	Locked(key string) (time.Duration, error)
+	// Reset drops failures and lock for key
+	Reset(key string) error
+}
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+type failState struct {
+	count       int
+	expires     time.Time
+	lockedUntil time.Time
+}
+
+// MemoryStore in-memory implementation of Store
+type MemoryStore struct {
+	mu            sync.Mutex
+	buckets       map[string]*bucketState
+	failures      map[string]*failState
+	sweepInterval time.Duration
+	lastSweep     time.Time
+	now           func() time.Time
+}
+
+// NewMemoryStore constructor for MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:       make(map[string]*bucketState),
+		failures:      make(map[string]*failState),
+		sweepInterval: 10 * time.Minute,
+		lastSweep:     time.Now(),
+		now:           time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(key string, b Bucket) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	st, ok := s.buckets[key]
+	if !ok {
+		st = &bucketState{
+			tokens: float64(b.Burst),
+			last:   now,
+		}
+		s.buckets[key] = st
+	}
+
+	st.tokens += now.Sub(st.last).Seconds() * b.Rate
+	if st.tokens > float64(b.Burst) {
+		st.tokens = float64(b.Burst)
+	}
+	st.last = now
+
+	if st.tokens < 1 {
+		return time.Duration((1 - st.tokens) / b.Rate * float64(time.Second)), nil
+	}
+
+	st.tokens--
+	return 0, nil
+}
+
+func (s *MemoryStore) Fail(key string, resetAfter time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	st, ok := s.failures[key]
+	if !ok || now.After(st.expires) {
+		st = &failState{}
+		s.failures[key] = st
+	}
+
+	st.count++
+	st.expires = now.Add(resetAfter)
+
+	return st.count, nil
+}
+
+func (s *MemoryStore) Lock(key string, period time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.failures[key]
+	if !ok {
+		st = &failState{}
+		s.failures[key] = st
+	}
+
+	st.lockedUntil = s.now().Add(period)
+	if st.expires.Before(st.lockedUntil) {
+		st.expires = st.lockedUntil
+	}
+	return nil
+}
+
+func (s *MemoryStore) Locked(key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.failures[key]
+	if !ok {
+		return 0, nil
+	}
+
+	if d := st.lockedUntil.Sub(s.now()); d > 0 {
+		return d, nil
+	}
+
+	return 0, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	return nil
+}
+
+// sweep drops stale entries, should be called under lock
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for k, b := range s.buckets {
+		if now.Sub(b.last) > s.sweepInterval {
+			delete(s.buckets, k)
+		}
+	}
+
+	for k, f := range s.failures {
+		if now.After(f.expires) {
+			delete(s.failures, k)
+		}
+	}
+}
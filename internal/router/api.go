@@ -0,0 +1,494 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+)
+
+const apiPrefix = "/api/v1"
+
+// apiParam parameter of versioned api route, in is one of path, query or body
+// form is name of form value the parameter is passed to handler with, the same as name if empty
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Form        string
+	Required    bool
+	Description string
+}
+
+func (p apiParam) formName() string {
+	if p.Form != "" {
+		return p.Form
+	}
+	return p.Name
+}
+
+// apiResponse describes how handler response is made consistent
+type apiResponse int
+
+const (
+	// responseJSON handler writes json itself
+	responseJSON apiResponse = iota
+	// responseToken handler writes raw token, it's wrapped into json object
+	responseToken
+	// responseEmpty handler writes empty body, status is replaced with 204
+	responseEmpty
+	// responseFile handler streams file content
+	responseFile
+)
+
+// apiRoute route of /api/v1/ surface served by the same handlers as legacy routes
+// schema is name of json response schema in openapi document
+// optional auth public route accepts token as well
+type apiRoute struct {
+	route
+	OperationID  string
+	Summary      string
+	Params       []apiParam
+	Multipart    bool
+	Response     apiResponse
+	Schema       string
+	Challenge    bool
+	OptionalAuth bool
+}
+
+var (
+	storageParam = apiParam{Name: "name", In: "path", Type: "string", Form: "storage", Required: true, Description: "storage name"}
+	fileParam    = apiParam{Name: "file", In: "path", Type: "string", Required: true, Description: "file name"}
+	versionParam = apiParam{Name: "version", In: "path", Type: "integer", Required: true, Description: "file version"}
+
+	permanentParam = apiParam{Name: "permanent", In: "query", Type: "boolean", Description: "use permanent storage"}
+	ttlParam       = apiParam{Name: "ttl", In: "query", Type: "string", Description: "file lifetime in seconds or duration like 90m"}
+	conflictParam  = apiParam{Name: "conflict", In: "query", Type: "string", Description: "overwrite, rename or fail"}
+)
+
+func configureAPI(h handler) []apiRoute {
+	return []apiRoute{
+		{
+			route:       route{Pattern: "/storages", Methods: "POST", Public: true, Handler: http.HandlerFunc(h.RegisterHandler)},
+			OperationID: "register",
+			Summary:     "Register storage",
+			Params: []apiParam{
+				{Name: "name", In: "body", Type: "string", Required: true},
+				{Name: "password", In: "body", Type: "string", Required: true},
+				{Name: "email", In: "body", Type: "string"},
+			},
+			Response: responseToken,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}", Methods: "DELETE", RequireMFA: true, Handler: http.HandlerFunc(h.DeleteAccountHandler)},
+			OperationID: "deleteStorage",
+			Summary:     "Delete storage with its account",
+			Params: []apiParam{
+				storageParam,
+				{Name: "password", In: "body", Type: "string", Required: true},
+			},
+			Response: responseEmpty,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/login", Methods: "POST", Public: true, Handler: http.HandlerFunc(h.LoginHandler)},
+			OperationID: "login",
+			Summary:     "Login into storage, responds with mfa challenge if two-factor authentication is enabled",
+			Params: []apiParam{
+				storageParam,
+				{Name: "password", In: "body", Type: "string", Required: true},
+			},
+			Response:  responseToken,
+			Challenge: true,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/login/mfa", Methods: "POST", Public: true, Handler: http.HandlerFunc(h.LoginMFAHandler)},
+			OperationID: "loginMFA",
+			Summary:     "Complete mfa challenge",
+			Params: []apiParam{
+				storageParam,
+				{Name: "challenge", In: "body", Type: "string", Required: true},
+				{Name: "code", In: "body", Type: "string", Required: true},
+			},
+			Response: responseToken,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/mfa/enroll", Methods: "POST", Handler: http.HandlerFunc(h.EnrollMFAHandler)},
+			OperationID: "enrollMFA",
+			Summary:     "Start two-factor authentication enrollment",
+			Params:      []apiParam{storageParam},
+			Schema:      "Enrollment",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/mfa/confirm", Methods: "POST", Handler: http.HandlerFunc(h.ConfirmMFAHandler)},
+			OperationID: "confirmMFA",
+			Summary:     "Confirm two-factor authentication enrollment",
+			Params: []apiParam{
+				storageParam,
+				{Name: "code", In: "body", Type: "string", Required: true},
+			},
+			Response: responseToken,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/mfa/disable", Methods: "POST", RequireMFA: true, Handler: http.HandlerFunc(h.DisableMFAHandler)},
+			OperationID: "disableMFA",
+			Summary:     "Disable two-factor authentication",
+			Params:      []apiParam{storageParam},
+			Response:    responseToken,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/password", Methods: "PUT", Public: true, Handler: http.HandlerFunc(h.ChangePasswordHandler)},
+			OperationID: "changePassword",
+			Summary:     "Change storage password, storages with two-factor authentication require token completed with second factor",
+			Params: []apiParam{
+				storageParam,
+				{Name: "old_password", In: "body", Type: "string", Required: true},
+				{Name: "new_password", In: "body", Type: "string", Required: true},
+			},
+			Response:     responseToken,
+			Challenge:    true,
+			OptionalAuth: true,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/password/reset", Methods: "POST", Public: true, Handler: http.HandlerFunc(h.RequestPasswordResetHandler)},
+			OperationID: "requestPasswordReset",
+			Summary:     "Send password reset token to storage email",
+			Params:      []apiParam{storageParam},
+			Response:    responseEmpty,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/password/reset/confirm", Methods: "POST", Public: true, Handler: http.HandlerFunc(h.ResetPasswordHandler)},
+			OperationID: "resetPassword",
+			Summary:     "Set new password with reset token",
+			Params: []apiParam{
+				storageParam,
+				{Name: "token", In: "body", Type: "string", Required: true},
+				{Name: "new_password", In: "body", Type: "string", Required: true},
+			},
+			Response:  responseToken,
+			Challenge: true,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/export", Methods: "GET", RequireMFA: true, Handler: http.HandlerFunc(h.ExportAccountHandler)},
+			OperationID: "exportStorage",
+			Summary:     "Export storage files, metadata and history as zip archive",
+			Params:      []apiParam{storageParam},
+			Response:    responseFile,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files", Methods: "GET", Handler: http.HandlerFunc(h.GetFileList)},
+			OperationID: "listFiles",
+			Summary:     "List files, single page with next cursor is returned if page_size or cursor is set",
+			Params: []apiParam{
+				storageParam,
+				permanentParam,
+				{Name: "page_size", In: "query", Type: "integer"},
+				{Name: "cursor", In: "query", Type: "string"},
+				{Name: "prefix", In: "query", Type: "string"},
+				{Name: "sort", In: "query", Type: "string", Description: "name, size or mod_time"},
+			},
+			Schema: "FileList",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files", Methods: "POST", CreateStorage: true, Handler: http.HandlerFunc(h.UploadHandler)},
+			OperationID: "uploadFiles",
+			Summary:     "Upload files from multipart form",
+			Params:      []apiParam{storageParam, permanentParam, ttlParam, conflictParam},
+			Multipart:   true,
+			Schema:      "Files",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/texts", Methods: "POST", CreateStorage: true, Handler: http.HandlerFunc(h.ShareTextHandler)},
+			OperationID: "shareText",
+			Summary:     "Store text as file",
+			Params: []apiParam{
+				storageParam,
+				permanentParam,
+				{Name: "title", In: "body", Type: "string", Required: true},
+				{Name: "body", In: "body", Type: "string", Required: true},
+				{Name: "ttl", In: "body", Type: "string"},
+				{Name: "conflict", In: "body", Type: "string"},
+			},
+			Schema: "File",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files/{file}", Methods: "GET", Handler: http.HandlerFunc(h.GetFileHandler)},
+			OperationID: "getFile",
+			Summary:     "Download file",
+			Params: []apiParam{
+				storageParam,
+				fileParam,
+				permanentParam,
+				{Name: "inline", In: "query", Type: "boolean", Description: "show file in browser instead of download"},
+			},
+			Response: responseFile,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files/{file}", Methods: "DELETE", Handler: http.HandlerFunc(h.RemoveHandler)},
+			OperationID: "removeFile",
+			Summary:     "Move file to trash",
+			Params: []apiParam{
+				storageParam,
+				{Name: "file", In: "path", Type: "string", Form: "fileName", Required: true, Description: "file name"},
+				permanentParam,
+				{Name: "force", In: "query", Type: "boolean", Description: "remove file permanently"},
+			},
+			Response: responseEmpty,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files/{file}/preview", Methods: "GET", Handler: http.HandlerFunc(h.PreviewHandler)},
+			OperationID: "previewFile",
+			Summary:     "Get image thumbnail or text snippet",
+			Params: []apiParam{
+				storageParam,
+				fileParam,
+				permanentParam,
+				{Name: "size", In: "query", Type: "integer", Description: "thumbnail size in pixels"},
+			},
+			Response: responseFile,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files/{file}/versions", Methods: "GET", Handler: http.HandlerFunc(h.GetVersionListHandler)},
+			OperationID: "listVersions",
+			Summary:     "List versions of file in permanent storage",
+			Params:      []apiParam{storageParam, fileParam},
+			Schema:      "Versions",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files/{file}/versions/prune", Methods: "POST", RequireMFA: true, Handler: http.HandlerFunc(h.PruneVersionsHandler)},
+			OperationID: "pruneVersions",
+			Summary:     "Remove old versions of file, configured policy is used for omitted values",
+			Params: []apiParam{
+				storageParam,
+				fileParam,
+				{Name: "keep", In: "body", Type: "integer"},
+				{Name: "max_age", In: "body", Type: "integer", Description: "age in seconds"},
+			},
+			Schema: "Removed",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files/{file}/versions/{version}", Methods: "GET", Handler: http.HandlerFunc(h.GetVersionHandler)},
+			OperationID: "getVersion",
+			Summary:     "Download file version",
+			Params:      []apiParam{storageParam, fileParam, versionParam},
+			Response:    responseFile,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/files/{file}/versions/{version}/restore", Methods: "POST", Handler: http.HandlerFunc(h.RestoreVersionHandler)},
+			OperationID: "restoreVersion",
+			Summary:     "Restore file version",
+			Params:      []apiParam{storageParam, fileParam, versionParam},
+			Response:    responseEmpty,
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/search", Methods: "GET", Handler: http.HandlerFunc(h.SearchHandler)},
+			OperationID: "search",
+			Summary:     "Search files by name, size, date and content",
+			Params: []apiParam{
+				storageParam,
+				permanentParam,
+				{Name: "file_name", In: "query", Type: "string", Form: "name", Description: "file name pattern"},
+				{Name: "q", In: "query", Type: "string", Description: "content query"},
+				{Name: "min_size", In: "query", Type: "integer"},
+				{Name: "max_size", In: "query", Type: "integer"},
+				{Name: "from", In: "query", Type: "integer", Description: "unix time"},
+				{Name: "to", In: "query", Type: "integer", Description: "unix time"},
+				{Name: "sort", In: "query", Type: "string", Description: "name, size or mod_time"},
+				{Name: "order", In: "query", Type: "string", Description: "asc or desc"},
+			},
+			Schema: "SearchResult",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/trash", Methods: "GET", Handler: http.HandlerFunc(h.GetTrashListHandler)},
+			OperationID: "listTrash",
+			Summary:     "List files in trash",
+			Params:      []apiParam{storageParam, permanentParam},
+			Schema:      "TrashFiles",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/trash", Methods: "DELETE", Handler: http.HandlerFunc(h.EmptyTrashHandler)},
+			OperationID: "emptyTrash",
+			Summary:     "Remove all files from trash permanently",
+			Params:      []apiParam{storageParam, permanentParam},
+			Schema:      "Removed",
+		},
+		{
+			route:       route{Pattern: "/storages/{name}/trash/{file}/restore", Methods: "POST", Handler: http.HandlerFunc(h.RestoreTrashHandler)},
+			OperationID: "restoreTrash",
+			Summary:     "Restore file from trash",
+			Params: []apiParam{
+				storageParam,
+				{Name: "file", In: "path", Type: "string", Form: "fileName", Required: true, Description: "file name"},
+				permanentParam,
+				{Name: "deleted_at", In: "body", Type: "integer", Description: "deletion time of file to restore, the latest one by default"},
+			},
+			Response: responseEmpty,
+		},
+		{
+			route:       route{Pattern: "/admin/storages/orphaned", Methods: "GET", Admin: true, Handler: http.HandlerFunc(h.OrphanedStoragesHandler)},
+			OperationID: "listOrphanedStorages",
+			Summary:     "List storages without registered or anonymous public user",
+			Schema:      "Names",
+		},
+		{
+			route:       route{Pattern: "/admin/storages/orphaned/{name}", Methods: "DELETE", Admin: true, Handler: http.HandlerFunc(h.RemoveOrphanedStorageHandler)},
+			OperationID: "removeOrphanedStorage",
+			Summary:     "Remove storage without registered or anonymous public user",
+			Params: []apiParam{
+				{Name: "name", In: "path", Type: "string", Required: true, Description: "storage name"},
+			},
+			Response: responseEmpty,
+		},
+	}
+}
+
+func (ar apiRoute) hasBody() bool {
+	for _, p := range ar.Params {
+		if p.In == "body" {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyValue converts json value of body parameter to form value
+func bodyValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+// apiParameters passes path and json body parameters to handler as form values of legacy routes
+// body values are kept out of url, so they are not visible to outer handlers and logs
+func apiParameters(ar apiRoute, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := *r.URL
+		query := u.Query()
+
+		for _, p := range ar.Params {
+			switch p.In {
+			case "path":
+				query.Set(p.formName(), mux.Vars(r)[p.Name])
+			case "query":
+				v := query.Get(p.Name)
+				if p.formName() != p.Name && v != "" {
+					query.Set(p.formName(), v)
+				}
+
+				// legacy handlers check presence of flags like permanent only
+				if b, err := strconv.ParseBool(v); p.Type == "boolean" && err == nil && !b {
+					query.Del(p.formName())
+				}
+			}
+		}
+		u.RawQuery = query.Encode()
+
+		form := make(url.Values, len(query))
+		for k, v := range query {
+			form[k] = v
+		}
+
+		if ar.hasBody() && r.ContentLength != 0 {
+			if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+				httperror.NewInvalidParams("request body").WithError(fmt.Errorf("unsupported content type: %q", ct)).WriteJSON(w)
+				return
+			}
+
+			var body map[string]interface{}
+			d := json.NewDecoder(r.Body)
+			d.UseNumber()
+			if err := d.Decode(&body); err != nil {
+				httperror.NewInvalidParams("request body").WithError(err).WriteJSON(w)
+				return
+			}
+
+			for _, p := range ar.Params {
+				v, ok := body[p.Name]
+				if p.In != "body" || !ok || v == nil {
+					continue
+				}
+
+				s, err := bodyValue(v)
+				if err != nil {
+					httperror.NewInvalidParams(fmt.Sprintf("invalid %s", p.Name)).WithError(err).WriteJSON(w)
+					return
+				}
+				form.Set(p.formName(), s)
+			}
+		}
+
+		r = r.WithContext(r.Context())
+		r.URL = &u
+		r.Form = form
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseRecorder keeps response until handler is completed
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	return rr.body.Write(b)
+}
+
+// apiResult makes responses with raw token or without body consistent with json ones
+func apiResult(ar apiRoute, next http.Handler) http.Handler {
+	if ar.Response != responseToken && ar.Response != responseEmpty {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rr := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rr, r)
+
+		status := rr.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if ct, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); ct == "application/json" || status != http.StatusOK {
+			w.WriteHeader(status)
+			w.Write(rr.body.Bytes())
+			return
+		}
+
+		if ar.Response == responseEmpty {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(struct {
+			Token string `json:"token"`
+		}{
+			Token: rr.body.String(),
+		})
+	})
+}
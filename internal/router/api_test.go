@@ -0,0 +1,440 @@
+package router
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+)
+
+type openAPIDoc struct {
+	Paths      map[string]map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+func newTestRouter(t *testing.T) (*mux.Router, openAPIDoc) {
+	t.Helper()
+
+	router := mux.NewRouter()
+	MakeRoutes(router, true, newTestHandler(), nopLogger{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, apiPrefix+"/openapi.json", nil))
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode openapi document: %v", err)
+	}
+	return router, doc
+}
+
+// operation finds documented path of request, template with more literal segments wins
+func (d openAPIDoc) operation(method string, path string) (string, map[string]interface{}) {
+	segments := strings.Split(strings.TrimPrefix(path, apiPrefix), "/")
+
+	best, bestLiterals := "", -1
+	for template := range d.Paths {
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+
+		literals := 0
+		for i, p := range parts {
+			if p == segments[i] {
+				literals++
+			} else if !strings.HasPrefix(p, "{") {
+				literals = -1
+				break
+			}
+		}
+
+		if literals > bestLiterals {
+			best, bestLiterals = template, literals
+		}
+	}
+
+	op, _ := d.Paths[best][strings.ToLower(method)].(map[string]interface{})
+	return best, op
+}
+
+// validate checks json value against subset of json schema used by openapi document
+func (d openAPIDoc) validate(s map[string]interface{}, v interface{}) error {
+	if ref, ok := s["$ref"].(string); ok {
+		return d.validate(d.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{}), v)
+	}
+
+	if variants, ok := s["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, vs := range variants {
+			if d.validate(vs.(map[string]interface{}), v) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%d of oneOf schemas matched %v", matched, v)
+		}
+		return nil
+	}
+
+	switch s["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %v", v)
+		}
+
+		required, _ := s["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("required property %s is missing", name)
+			}
+		}
+
+		properties, _ := s["properties"].(map[string]interface{})
+		for name, pv := range obj {
+			ps, ok := properties[name].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("undocumented property %s", name)
+			}
+			if err := d.validate(ps, pv); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected array, got %v", v)
+		}
+		for _, item := range items {
+			if err := d.validate(s["items"].(map[string]interface{}), item); err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("expected string, got %v", v)
+		}
+
+	case "integer":
+		if n, ok := v.(json.Number); !ok || strings.ContainsAny(n.String(), ".eE") {
+			return fmt.Errorf("expected integer, got %v", v)
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("expected boolean, got %v", v)
+		}
+	}
+
+	return nil
+}
+
+// checkResponse checks that status is documented and json body matches its schema
+func (d openAPIDoc) checkResponse(op map[string]interface{}, rr *httptest.ResponseRecorder) error {
+	responses := op["responses"].(map[string]interface{})
+	response, ok := responses[strconv.Itoa(rr.Code)].(map[string]interface{})
+	if !ok && rr.Code >= http.StatusBadRequest {
+		response, ok = responses["default"].(map[string]interface{})
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", rr.Code)
+	}
+
+	content, ok := response["content"].(map[string]interface{})
+	if !ok {
+		if rr.Body.Len() != 0 {
+			return fmt.Errorf("unexpected body of %d response", rr.Code)
+		}
+		return nil
+	}
+
+	media, ok := content["application/json"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		return fmt.Errorf("unexpected content type %q", ct)
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(rr.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return d.validate(media["schema"].(map[string]interface{}), v)
+}
+
+func multipartFile(t *testing.T, name string, content string) (string, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	return buf.String(), mw.FormDataContentType()
+}
+
+func TestAPIContract(t *testing.T) {
+	router, doc := newTestRouter(t)
+	upload, uploadType := multipartFile(t, "a.txt", "content")
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"POST", "/storages", "", `{"name":"alice","password":"secret"}`, http.StatusOK},
+		{"POST", "/storages", "", `{"name":"taken","password":"secret"}`, http.StatusBadRequest},
+		{"POST", "/storages", "", `[`, http.StatusBadRequest},
+		{"POST", "/storages/alice/login", "", `{"password":"secret"}`, http.StatusOK},
+		{"POST", "/storages/alice/login", "", `{"password":"wrong"}`, http.StatusBadRequest},
+		{"POST", "/storages/carol/login", "", `{"password":"secret"}`, http.StatusAccepted},
+		{"POST", "/storages/carol/login/mfa", "", `{"challenge":"challenge","code":"123456"}`, http.StatusOK},
+		{"POST", "/storages/alice/mfa/enroll", "alice", "", http.StatusOK},
+		{"POST", "/storages/alice/mfa/confirm", "alice", `{"code":"123456"}`, http.StatusOK},
+		{"POST", "/storages/carol/mfa/disable", "carol", "", http.StatusBadRequest},
+		{"POST", "/storages/carol/mfa/disable", "carol:mfa", "", http.StatusOK},
+		{"PUT", "/storages/alice/password", "", `{"old_password":"secret","new_password":"secret2"}`, http.StatusOK},
+		{"PUT", "/storages/carol/password", "", `{"old_password":"secret","new_password":"secret2"}`, http.StatusBadRequest},
+		{"PUT", "/storages/carol/password", "carol:mfa", `{"old_password":"secret","new_password":"secret2"}`, http.StatusAccepted},
+		{"POST", "/storages/alice/password/reset", "", "", http.StatusNoContent},
+		{"POST", "/storages/alice/password/reset/confirm", "", `{"token":"reset","new_password":"secret2"}`, http.StatusOK},
+		{"GET", "/storages/alice/export", "alice", "", http.StatusOK},
+		{"GET", "/storages/alice/files", "alice", "", http.StatusOK},
+		{"GET", "/storages/alice/files?page_size=1", "alice", "", http.StatusOK},
+		{"POST", "/storages/alice/files?permanent=true", "alice", upload, http.StatusOK},
+		{"POST", "/storages/alice/texts", "alice", `{"title":"note","body":"text"}`, http.StatusOK},
+		{"GET", "/storages/alice/files/a.txt", "alice", "", http.StatusOK},
+		{"GET", "/storages/alice/files/missing.txt", "alice", "", http.StatusBadRequest},
+		{"DELETE", "/storages/alice/files/a.txt", "alice", "", http.StatusNoContent},
+		{"GET", "/storages/alice/files/a.txt/preview", "alice", "", http.StatusOK},
+		{"GET", "/storages/alice/files/a.txt/versions", "alice", "", http.StatusOK},
+		{"POST", "/storages/alice/files/a.txt/versions/prune", "alice", `{"keep":1}`, http.StatusOK},
+		{"GET", "/storages/alice/files/a.txt/versions/1", "alice", "", http.StatusOK},
+		{"POST", "/storages/alice/files/a.txt/versions/1/restore", "alice", "", http.StatusNoContent},
+		{"GET", "/storages/alice/search?q=text", "alice", "", http.StatusOK},
+		{"GET", "/storages/alice/trash", "alice", "", http.StatusOK},
+		{"POST", "/storages/alice/trash/a.txt/restore", "alice", "", http.StatusNoContent},
+		{"DELETE", "/storages/alice/trash", "alice", "", http.StatusOK},
+		{"GET", "/admin/storages/orphaned", testAdminToken, "", http.StatusOK},
+		{"GET", "/admin/storages/orphaned", "alice", "", http.StatusBadRequest},
+		{"DELETE", "/admin/storages/orphaned/ghost", testAdminToken, "", http.StatusNoContent},
+		{"DELETE", "/admin/storages/orphaned/alice", testAdminToken, "", http.StatusBadRequest},
+		{"DELETE", "/admin/storages/orphaned/missing", testAdminToken, "", http.StatusBadRequest},
+		{"DELETE", "/storages/alice", "alice", `{"password":"secret"}`, http.StatusNoContent},
+		{"DELETE", "/storages/gone", "gone", `{"password":"secret"}`, http.StatusNoContent},
+	}
+
+	covered := make(map[string]bool)
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, apiPrefix+tc.path, strings.NewReader(tc.body))
+		switch {
+		case tc.body == upload:
+			r.Header.Set("Content-Type", uploadType)
+		case tc.body != "":
+			r.Header.Set("Content-Type", "application/json")
+		}
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+
+		if rr.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tc.method, tc.path, tc.status, rr.Code, rr.Body)
+			continue
+		}
+
+		template, op := doc.operation(tc.method, r.URL.Path)
+		if op == nil {
+			t.Errorf("%s %s: operation is not documented", tc.method, tc.path)
+			continue
+		}
+
+		if err := doc.checkResponse(op, rr); err != nil {
+			t.Errorf("%s %s: %v", tc.method, tc.path, err)
+		}
+
+		if rr.Code < http.StatusBadRequest {
+			covered[tc.method+" "+template] = true
+		}
+	}
+
+	var uncovered []string
+	for template, item := range doc.Paths {
+		for method := range item {
+			if key := strings.ToUpper(method) + " " + template; !covered[key] {
+				uncovered = append(uncovered, key)
+			}
+		}
+	}
+	sort.Strings(uncovered)
+
+	if len(uncovered) > 0 {
+		t.Errorf("operations without successful response: %v", uncovered)
+	}
+}
+
+func TestSecondFactorRequired(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		body   string
+	}{
+		{"GET", "/storages/carol/export", "carol", ""},
+		{"DELETE", "/storages/carol", "carol", `{"password":"secret"}`},
+		{"POST", "/storages/carol/mfa/disable", "carol", ""},
+		{"PUT", "/storages/carol/password", "", `{"old_password":"secret","new_password":"secret2"}`},
+		{"PUT", "/storages/carol/password", "alice:mfa", `{"old_password":"secret","new_password":"secret2"}`},
+	}
+
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, apiPrefix+tc.path, strings.NewReader(tc.body))
+		r.Header.Set("Content-Type", "application/json")
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+
+		var rsp httperror.Error
+		if err := json.NewDecoder(rr.Body).Decode(&rsp); err != nil {
+			t.Errorf("%s %s with token %q: expected error response, got status %d", tc.method, tc.path, tc.token, rr.Code)
+			continue
+		}
+
+		if rsp.Code != httperror.CodeUnauthorized {
+			t.Errorf("%s %s with token %q: expected unauthorized error, got %+v", tc.method, tc.path, tc.token, rsp)
+		}
+	}
+}
+
+func TestAccountExport(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	r := httptest.NewRequest("GET", apiPrefix+"/storages/alice/export", nil)
+	r.Header.Set("Authorization", "Bearer alice")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body)
+	}
+
+	body := rr.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+
+	var (
+		entries  []string
+		metadata struct {
+			Files    []json.RawMessage `json:"files"`
+			Versions []json.RawMessage `json:"versions"`
+			Trash    []json.RawMessage `json:"trash"`
+			Excluded []string          `json:"excluded"`
+		}
+	)
+	for _, f := range zr.File {
+		entries = append(entries, f.Name)
+		if f.Name != "metadata.json" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open metadata: %v", err)
+		}
+		err = json.NewDecoder(rc).Decode(&metadata)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("decode metadata: %v", err)
+		}
+	}
+
+	// current version 2 of permanent files is exported with them
+	expected := []string{
+		"files/temporary/a.txt", "files/temporary/b.txt",
+		"files/permanent/a.txt", "files/permanent/b.txt",
+		"files/versions/a.txt/1", "files/versions/b.txt/1",
+		"metadata.json", "history.json",
+	}
+	if strings.Join(entries, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected entries %v, got %v", expected, entries)
+	}
+
+	if len(metadata.Files) != 4 || len(metadata.Versions) != 2 || len(metadata.Trash) != 2 || len(metadata.Excluded) == 0 {
+		t.Errorf("unexpected metadata: %d files, %d versions, %d trashed, excluded %v",
+			len(metadata.Files), len(metadata.Versions), len(metadata.Trash), metadata.Excluded)
+	}
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	router, _ := newTestRouter(t)
+	upload, uploadType := multipartFile(t, "a.txt", "content")
+
+	tests := []struct {
+		method      string
+		path        string
+		token       string
+		body        string
+		contentType string
+	}{
+		{"POST", "/register/", "", "name=alice&password=secret", "application/x-www-form-urlencoded"},
+		{"POST", "/login/", "", "storage=alice&password=secret", "application/x-www-form-urlencoded"},
+		{"POST", "/upload/?storage=alice", "alice", upload, uploadType},
+		{"GET", "/list/?storage=alice", "alice", "", ""},
+		{"GET", "/file/?storage=alice&file=a.txt", "alice", "", ""},
+		{"GET", "/search/?storage=alice&q=text", "alice", "", ""},
+		{"POST", "/remove/", "alice", "storage=alice&fileName=a.txt", "application/x-www-form-urlencoded"},
+		{"GET", "/trash/?storage=alice", "alice", "", ""},
+		{"GET", "/admin/storages/orphaned/", testAdminToken, "", ""},
+	}
+
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s %s: unexpected status %d: %s", tc.method, tc.path, rr.Code, rr.Body)
+		}
+	}
+}
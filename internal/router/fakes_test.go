@@ -0,0 +1,280 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	microerrors "github.com/asim/go-micro/v3/errors"
+
+	gateway "github.com/Mikhalevich/filesharing/internal/handler"
+	"github.com/Mikhalevich/filesharing/internal/preview"
+	"github.com/Mikhalevich/filesharing/internal/storages"
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+	"github.com/Mikhalevich/filesharing/pkg/service"
+)
+
+// fake services answer with canned values, responses are checked by shape only
+// storage "taken" is registered, "carol" has two-factor authentication enabled
+// "ghost" has no user; token is storage name, with ":mfa" suffix after second factor
+// tokens without suffix have no enrollment claim like the ones issued before enrollment
+// storage of "gone" is already removed, file service answers with rpc error
+const (
+	testAdminToken    = "admin-token"
+	testMFACode       = "123456"
+	testWrongPassword = "wrong"
+)
+
+type fakeAuth struct{}
+
+func token(name string) *auth.Token {
+	return &auth.Token{Value: name}
+}
+
+func (fakeAuth) Create(user *auth.User) (*auth.Token, error) {
+	if user.GetName() == "taken" {
+		return nil, httperror.NewAlreadyExistError("user exists")
+	}
+	return token(user.GetName()), nil
+}
+
+func (fakeAuth) Auth(user *auth.User) (*auth.Token, *auth.MFAChallenge, error) {
+	if user.GetPassword() == testWrongPassword {
+		return nil, nil, httperror.NewNotMatchError("password not match")
+	}
+	if user.GetName() == "carol" {
+		return nil, &auth.MFAChallenge{Id: "challenge", ExpiresAt: time.Now().Add(time.Minute).Unix()}, nil
+	}
+	return token(user.GetName()), nil, nil
+}
+
+func (fakeAuth) AuthPublicUser(name string) (*auth.Token, error) {
+	return token(name), nil
+}
+
+func (fakeAuth) UserByToken(t string) (*auth.User, error) {
+	name := strings.TrimSuffix(t, ":mfa")
+	return &auth.User{
+		Id:          1,
+		Name:        name,
+		MfaEnrolled: name != t,
+		MfaVerified: name != t,
+	}, nil
+}
+
+func (fakeAuth) ChangePassword(name, oldPassword, newPassword string) (*auth.Token, error) {
+	if oldPassword == testWrongPassword {
+		return nil, httperror.NewNotMatchError("password not match")
+	}
+	return token(name), nil
+}
+
+func (fakeAuth) RequestPasswordReset(name string) (*auth.RequestPasswordResetResponse, error) {
+	return &auth.RequestPasswordResetResponse{Email: name + "@example.com", ResetToken: "reset", ExpiresAt: time.Now().Unix()}, nil
+}
+
+func (fakeAuth) ResetPassword(name, resetToken, newPassword string) (*auth.Token, error) {
+	return token(name), nil
+}
+
+func (fakeAuth) EnrollMFA(name string) (*auth.EnrollMFAResponse, error) {
+	return &auth.EnrollMFAResponse{Secret: "JBSWY3DPEHPK3PXP", RecoveryCodes: []string{"recovery"}}, nil
+}
+
+func (fakeAuth) ConfirmMFA(name, code string) (*auth.Token, error) {
+	return fakeAuth{}.VerifyMFA(name, "", code)
+}
+
+func (fakeAuth) DisableMFA(name string) (*auth.Token, error) {
+	return token(name), nil
+}
+
+func (fakeAuth) VerifyMFA(name, challengeID, code string) (*auth.Token, error) {
+	if code != testMFACode {
+		return nil, httperror.NewNotMatchError("invalid code")
+	}
+	return token(name + ":mfa"), nil
+}
+
+func (fakeAuth) MFAEnrolled(name string) (bool, error) {
+	return name == "carol", nil
+}
+
+func (fakeAuth) Delete(user *auth.User) error {
+	return nil
+}
+
+func (fakeAuth) ExistingUsers(names []string) ([]string, error) {
+	var existing []string
+	for _, n := range names {
+		if n != "ghost" {
+			existing = append(existing, n)
+		}
+	}
+	return existing, nil
+}
+
+type fakeFiles struct{}
+
+func testFile(name string) *file.File {
+	return &file.File{Name: name, Size: 7, ModTime: time.Now().Unix(), ContentType: "text/plain"}
+}
+
+func (fakeFiles) Files(storage string, isPermanent bool) ([]*file.File, error) {
+	files := []*file.File{testFile("a.txt"), testFile("b.txt")}
+	if isPermanent {
+		for _, f := range files {
+			f.Version = 2
+		}
+	}
+	return files, nil
+}
+
+func (fakeFiles) FilesPage(req *file.ListRequest) (*file.ListResponse, error) {
+	return &file.ListResponse{Files: []*file.File{testFile("a.txt")}, NextCursor: "next", Total: 2, Paginated: true}, nil
+}
+
+func (fakeFiles) Create(storage string, withPermanent bool) error { return nil }
+
+func (fakeFiles) IsStorageExists(storage string) (bool, error) { return true, nil }
+
+func (fakeFiles) Storages() ([]string, error) { return []string{"alice", "ghost"}, nil }
+
+func (fakeFiles) RemoveStorage(storage string) error {
+	if storage == "gone" {
+		return microerrors.NotFound("filesharing.file", "storage %s not found", storage)
+	}
+	return nil
+}
+
+func (fakeFiles) RemoveUnownedStorage(storage string) error {
+	switch storage {
+	case "ghost":
+		return nil
+	case "missing":
+		return microerrors.NotFound("filesharing.file", "storage %s not found", storage)
+	}
+	return microerrors.Conflict("filesharing.file", "storage %s belongs to user", storage)
+}
+
+func (fakeFiles) Remove(storage string, isPermanent bool, fileName string) error { return nil }
+
+func (fakeFiles) Trash(storage string, isPermanent bool, fileName string) (*file.File, error) {
+	return testFile(fileName), nil
+}
+
+func (fakeFiles) TrashFiles(storage string, isPermanent bool) ([]*file.File, error) {
+	f := testFile("a.txt")
+	f.DeletedAt = time.Now().Unix()
+	return []*file.File{f}, nil
+}
+
+func (fakeFiles) RestoreFromTrash(storage string, isPermanent bool, fileName string, deletedAt int64) (*file.File, error) {
+	return testFile(fileName), nil
+}
+
+func (fakeFiles) EmptyTrash(storage string, isPermanent bool) (int64, error) { return 1, nil }
+
+func (fakeFiles) Get(storage string, isPermanent bool, fileName string, w io.Writer) error {
+	if fileName == "missing.txt" {
+		return httperror.NewNotExistError("no such file")
+	}
+	_, err := io.WriteString(w, "content")
+	return err
+}
+
+func (fakeFiles) GetVersion(storage string, fileName string, version int64, w io.Writer) error {
+	_, err := io.WriteString(w, "content")
+	return err
+}
+
+func (fakeFiles) Versions(storage string, fileName string) ([]*file.File, error) {
+	v1, v2 := testFile(fileName), testFile(fileName)
+	v1.Version, v2.Version = 1, 2
+	return []*file.File{v2, v1}, nil
+}
+
+func (fakeFiles) RestoreVersion(storage string, fileName string, version int64) (*file.File, error) {
+	return testFile(fileName), nil
+}
+
+func (fakeFiles) PruneVersions(storage string, fileName string, keep int, maxAge time.Duration) (int64, error) {
+	return 1, nil
+}
+
+func (fakeFiles) Upload(storage string, isPermanent bool, fileName string, ttl time.Duration, conflict file.ConflictPolicy, r io.Reader) (*file.File, error) {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+	return testFile(fileName), nil
+}
+
+type fakeSearcher struct{}
+
+func (fakeSearcher) Search(storage string, isPermanent bool, query string) ([]string, error) {
+	return []string{"a.txt"}, nil
+}
+
+type fakePreviewer struct{}
+
+func (fakePreviewer) Preview(storage string, isPermanent bool, fileName string, size int) (*preview.Preview, error) {
+	return &preview.Preview{ContentType: "image/png", Data: []byte("\x89PNG")}, nil
+}
+
+type fakeHistory struct{}
+
+func (fakeHistory) List(userID int64) ([]*event.FileEvent, error) { return nil, nil }
+
+type fakeEvent struct{}
+
+func (fakeEvent) Publish(ctx context.Context, msg interface{}, opts ...client.PublishOption) error {
+	return nil
+}
+
+type fakeLimiter struct{}
+
+func (fakeLimiter) Allow(ip, storage string) (time.Duration, error)  { return 0, nil }
+func (fakeLimiter) Locked(ip, storage string) (time.Duration, error) { return 0, nil }
+func (fakeLimiter) Fail(ip, storage string) (time.Duration, error)   { return 0, nil }
+func (fakeLimiter) Reset(ip, storage string) error                   { return nil }
+func (fakeLimiter) ClientIP(r *http.Request) string                  { return "127.0.0.1" }
+
+type fakePolicy struct{}
+
+func (fakePolicy) Check(name, password string) error { return nil }
+
+type fakeMailer struct{}
+
+func (fakeMailer) SendPasswordReset(to string, name string, token string, expiresAt time.Time) error {
+	return nil
+}
+
+type nopLogger struct{}
+
+func (l nopLogger) Debugf(format string, args ...interface{})               {}
+func (l nopLogger) Infof(format string, args ...interface{})                {}
+func (l nopLogger) Warnf(format string, args ...interface{})                {}
+func (l nopLogger) Errorf(format string, args ...interface{})               {}
+func (l nopLogger) Debug(args ...interface{})                               {}
+func (l nopLogger) Info(args ...interface{})                                {}
+func (l nopLogger) Warn(args ...interface{})                                {}
+func (l nopLogger) Error(args ...interface{})                               {}
+func (l nopLogger) WithContext(ctx context.Context) service.Logger          { return l }
+func (l nopLogger) WithError(err error) service.Logger                      { return l }
+func (l nopLogger) WithField(key string, value interface{}) service.Logger  { return l }
+func (l nopLogger) WithFields(fields map[string]interface{}) service.Logger { return l }
+
+func newTestHandler() *gateway.Handler {
+	cfg := gateway.DefaultConfig()
+	cfg.Storages.AdminToken = testAdminToken
+
+	return gateway.NewHandler(fakeAuth{}, fakeFiles{}, fakeHistory{}, fakeSearcher{}, fakePreviewer{}, nopLogger{},
+		fakeEvent{}, fakeEvent{}, fakeEvent{}, storages.NewCache(storages.DefaultConfig()),
+		fakeLimiter{}, fakePolicy{}, fakeMailer{}, cfg)
+}
@@ -0,0 +1,305 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	gateway "github.com/Mikhalevich/filesharing/internal/handler"
+)
+
+type schema map[string]interface{}
+
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(items schema) schema {
+	return schema{"type": "array", "items": items}
+}
+
+func object(required []string, properties map[string]schema) schema {
+	s := schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+var (
+	stringSchema  = schema{"type": "string"}
+	integerSchema = schema{"type": "integer", "format": "int64"}
+)
+
+// typeSchema makes schema of go type encoded by handler, types found in refs are referenced
+func typeSchema(t reflect.Type, refs map[reflect.Type]schema) schema {
+	if s, ok := refs[t]; ok {
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return stringSchema
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return integerSchema
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Slice:
+		return arrayOf(typeSchema(t.Elem(), refs))
+	case reflect.Struct:
+		return structSchema(t, refs)
+	}
+	return schema{}
+}
+
+// structSchema makes object schema from json tags, fields without omitempty are required
+func structSchema(t reflect.Type, refs map[reflect.Type]schema) schema {
+	var required []string
+	properties := make(map[string]schema, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		if tag[0] == "-" || f.PkgPath != "" {
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = typeSchema(f.Type, refs)
+
+		omitEmpty := false
+		for _, o := range tag[1:] {
+			omitEmpty = omitEmpty || o == "omitempty"
+		}
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	return object(required, properties)
+}
+
+// apiSchemas json schemas of handler responses
+// file schemas are generated from types encoded by handler, so they can't drift apart
+func apiSchemas() map[string]schema {
+	refs := map[reflect.Type]schema{
+		reflect.TypeOf([]gateway.FileInfo{}): ref("Files"),
+	}
+
+	return map[string]schema{
+		"Error": object([]string{"code", "description"}, map[string]schema{
+			"code":        schema{"type": "integer"},
+			"description": stringSchema,
+		}),
+		"Token": object([]string{"token"}, map[string]schema{
+			"token": stringSchema,
+		}),
+		"MFAChallenge": object([]string{"challenge", "expires_at"}, map[string]schema{
+			"challenge":  stringSchema,
+			"expires_at": integerSchema,
+		}),
+		"Enrollment": object([]string{"otpauth_uri", "recovery_codes"}, map[string]schema{
+			"otpauth_uri":    stringSchema,
+			"recovery_codes": arrayOf(stringSchema),
+		}),
+		"File":     typeSchema(reflect.TypeOf(gateway.FileInfo{}), refs),
+		"Files":    arrayOf(ref("File")),
+		"FilePage": typeSchema(reflect.TypeOf(gateway.FilePage{}), refs),
+		"FileList": schema{"oneOf": []schema{ref("Files"), ref("FilePage")}},
+		"TrashFiles": arrayOf(object([]string{"name", "size", "mod_time", "deleted_at"}, map[string]schema{
+			"name":       stringSchema,
+			"size":       integerSchema,
+			"mod_time":   integerSchema,
+			"deleted_at": integerSchema,
+		})),
+		"Versions": arrayOf(object([]string{"version", "size", "mod_time"}, map[string]schema{
+			"version":  integerSchema,
+			"size":     integerSchema,
+			"mod_time": integerSchema,
+		})),
+		"SearchResult": ref("Files"),
+		"Removed": object([]string{"removed"}, map[string]schema{
+			"removed": integerSchema,
+		}),
+		"Names": arrayOf(stringSchema),
+	}
+}
+
+func jsonContent(s schema) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": s},
+	}
+}
+
+func paramSchema(p apiParam) schema {
+	if p.Type == "integer" {
+		return integerSchema
+	}
+	return schema{"type": p.Type}
+}
+
+// operation makes openapi operation object of route
+func operation(ar apiRoute) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": ar.OperationID,
+		"summary":     ar.Summary,
+	}
+
+	var (
+		params   []map[string]interface{}
+		required []string
+	)
+	properties := make(map[string]schema)
+	for _, p := range ar.Params {
+		if p.In == "body" {
+			properties[p.Name] = paramSchema(p)
+			if p.Required {
+				required = append(required, p.Name)
+			}
+			continue
+		}
+
+		param := map[string]interface{}{
+			"name":     p.Name,
+			"in":       p.In,
+			"required": p.Required,
+			"schema":   paramSchema(p),
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch {
+	case ar.Multipart:
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{
+					"schema": object(nil, map[string]schema{
+						"file": arrayOf(schema{"type": "string", "format": "binary"}),
+					}),
+				},
+			},
+		}
+	case len(properties) > 0:
+		op["requestBody"] = map[string]interface{}{
+			"required": len(required) > 0,
+			"content":  jsonContent(object(required, properties)),
+		}
+	}
+
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{
+			"description": "error",
+			"content":     jsonContent(ref("Error")),
+		},
+	}
+
+	switch ar.Response {
+	case responseToken:
+		responses["200"] = map[string]interface{}{
+			"description": "token",
+			"content":     jsonContent(ref("Token")),
+		}
+		if ar.Challenge {
+			responses["202"] = map[string]interface{}{
+				"description": "second factor is required",
+				"content":     jsonContent(ref("MFAChallenge")),
+			}
+		}
+	case responseEmpty:
+		responses["204"] = map[string]interface{}{"description": "done"}
+	case responseFile:
+		responses["200"] = map[string]interface{}{
+			"description": "content",
+			"content": map[string]interface{}{
+				"application/octet-stream": map[string]interface{}{
+					"schema": schema{"type": "string", "format": "binary"},
+				},
+			},
+		}
+	default:
+		responses["200"] = map[string]interface{}{
+			"description": "result",
+			"content":     jsonContent(ref(ar.Schema)),
+		}
+	}
+	op["responses"] = responses
+
+	switch {
+	case ar.Admin:
+		op["security"] = []map[string][]string{{"adminToken": {}}}
+	case ar.OptionalAuth:
+		op["security"] = []map[string][]string{{}, {"bearerAuth": {}}}
+	case ar.Public:
+		op["security"] = []map[string][]string{}
+	}
+
+	return op
+}
+
+// openAPI generates openapi 3 document from api routes
+func openAPI(routes []apiRoute) map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	for _, ar := range routes {
+		item, ok := paths[ar.Pattern]
+		if !ok {
+			item = make(map[string]interface{})
+			paths[ar.Pattern] = item
+		}
+
+		for _, m := range strings.Split(ar.Methods, ",") {
+			item[strings.ToLower(m)] = operation(ar)
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "filesharing",
+			"version":     "1.0.0",
+			"description": "Storages without password are available without token if public storages are enabled, token is returned in X-Token header then.",
+		},
+		"servers": []map[string]interface{}{
+			{"url": apiPrefix},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": apiSchemas(),
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+				"adminToken": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+		"security": []map[string][]string{{"bearerAuth": {}}},
+	}
+}
+
+// openAPIHandler serves document generated once on start
+func openAPIHandler(routes []apiRoute) (http.Handler, error) {
+	doc, err := json.MarshalIndent(openAPI(routes), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}), nil
+}
@@ -6,14 +6,21 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/Mikhalevich/filesharing/internal/names"
 	"github.com/Mikhalevich/filesharing/pkg/ctxinfo"
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
 )
 
+// route creates storage on request if CreateStorage is set
+// admin routes are authorized by admin token instead of user one
 type route struct {
-	Pattern string
-	Methods string
-	Public  bool
-	Handler http.Handler
+	Pattern       string
+	Methods       string
+	Public        bool
+	RequireMFA    bool
+	CreateStorage bool
+	Admin         bool
+	Handler       http.Handler
 }
 
 type handler interface {
@@ -25,8 +32,30 @@ type handler interface {
 	RemoveHandler(w http.ResponseWriter, r *http.Request)
 	GetFileHandler(w http.ResponseWriter, r *http.Request)
 	ShareTextHandler(w http.ResponseWriter, r *http.Request)
+	ChangePasswordHandler(w http.ResponseWriter, r *http.Request)
+	RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request)
+	ResetPasswordHandler(w http.ResponseWriter, r *http.Request)
+	LoginMFAHandler(w http.ResponseWriter, r *http.Request)
+	EnrollMFAHandler(w http.ResponseWriter, r *http.Request)
+	ConfirmMFAHandler(w http.ResponseWriter, r *http.Request)
+	DisableMFAHandler(w http.ResponseWriter, r *http.Request)
+	ExportAccountHandler(w http.ResponseWriter, r *http.Request)
+	SearchHandler(w http.ResponseWriter, r *http.Request)
+	PreviewHandler(w http.ResponseWriter, r *http.Request)
+	GetTrashListHandler(w http.ResponseWriter, r *http.Request)
+	RestoreTrashHandler(w http.ResponseWriter, r *http.Request)
+	EmptyTrashHandler(w http.ResponseWriter, r *http.Request)
+	GetVersionListHandler(w http.ResponseWriter, r *http.Request)
+	GetVersionHandler(w http.ResponseWriter, r *http.Request)
+	RestoreVersionHandler(w http.ResponseWriter, r *http.Request)
+	PruneVersionsHandler(w http.ResponseWriter, r *http.Request)
+	DeleteAccountHandler(w http.ResponseWriter, r *http.Request)
+	OrphanedStoragesHandler(w http.ResponseWriter, r *http.Request)
+	RemoveOrphanedStorageHandler(w http.ResponseWriter, r *http.Request)
 	CheckAuthMiddleware(next http.Handler) http.Handler
+	RequireMFAMiddleware(next http.Handler) http.Handler
 	CreateStorageMiddleware(next http.Handler) http.Handler
+	AdminMiddleware(next http.Handler) http.Handler
 	RecoverMiddleware(next http.Handler) http.Handler
 }
 
@@ -55,6 +84,104 @@ func configure(h handler) []route {
 			Public:  true,
 			Handler: http.HandlerFunc(h.LoginHandler),
 		},
+		{
+			Pattern: "/login/mfa/",
+			Methods: "POST",
+			Public:  true,
+			Handler: http.HandlerFunc(h.LoginMFAHandler),
+		},
+		{
+			Pattern: "/mfa/enroll/",
+			Methods: "POST",
+			Handler: http.HandlerFunc(h.EnrollMFAHandler),
+		},
+		{
+			Pattern: "/mfa/confirm/",
+			Methods: "POST",
+			Handler: http.HandlerFunc(h.ConfirmMFAHandler),
+		},
+		{
+			Pattern:    "/mfa/disable/",
+			Methods:    "POST",
+			RequireMFA: true,
+			Handler:    http.HandlerFunc(h.DisableMFAHandler),
+		},
+		{
+			Pattern: "/search/",
+			Methods: "GET",
+			Handler: http.HandlerFunc(h.SearchHandler),
+		},
+		{
+			Pattern: "/preview/",
+			Methods: "GET",
+			Handler: http.HandlerFunc(h.PreviewHandler),
+		},
+		{
+			Pattern: "/trash/",
+			Methods: "GET",
+			Handler: http.HandlerFunc(h.GetTrashListHandler),
+		},
+		{
+			Pattern: "/trash/restore/",
+			Methods: "POST",
+			Handler: http.HandlerFunc(h.RestoreTrashHandler),
+		},
+		{
+			Pattern: "/trash/empty/",
+			Methods: "POST",
+			Handler: http.HandlerFunc(h.EmptyTrashHandler),
+		},
+		{
+			Pattern: "/versions/",
+			Methods: "GET",
+			Handler: http.HandlerFunc(h.GetVersionListHandler),
+		},
+		{
+			Pattern: "/versions/file/",
+			Methods: "GET",
+			Handler: http.HandlerFunc(h.GetVersionHandler),
+		},
+		{
+			Pattern: "/versions/restore/",
+			Methods: "POST",
+			Handler: http.HandlerFunc(h.RestoreVersionHandler),
+		},
+		{
+			Pattern:    "/versions/prune/",
+			Methods:    "POST",
+			RequireMFA: true,
+			Handler:    http.HandlerFunc(h.PruneVersionsHandler),
+		},
+		{
+			Pattern:    "/account/export/",
+			Methods:    "GET",
+			RequireMFA: true,
+			Handler:    http.HandlerFunc(h.ExportAccountHandler),
+		},
+		{
+			Pattern:    "/account/delete/",
+			Methods:    "POST",
+			RequireMFA: true,
+			Handler:    http.HandlerFunc(h.DeleteAccountHandler),
+		},
+		{
+			Pattern: "/password/change/",
+			Methods: "POST",
+			Public:  true,
+			Handler: http.HandlerFunc(h.ChangePasswordHandler),
+		},
+		{
+			Pattern: "/password/reset/",
+			Methods: "POST",
+			Public:  true,
+			Handler: http.HandlerFunc(h.RequestPasswordResetHandler),
+		},
+		{
+			Pattern: "/password/reset/confirm/",
+			Methods: "POST",
+			Public:  true,
+			Handler: http.HandlerFunc(h.ResetPasswordHandler),
+		},
 		{
 			Pattern: "/index.html",
 			Methods: "GET",
@@ -71,9 +198,10 @@ func configure(h handler) []route {
 			Handler: http.HandlerFunc(h.GetFileList),
 		},
 		{
-			Pattern: "/upload/",
-			Methods: "POST",
-			Handler: http.HandlerFunc(h.UploadHandler),
+			Pattern:       "/upload/",
+			Methods:       "POST",
+			CreateStorage: true,
+			Handler:       http.HandlerFunc(h.UploadHandler),
 		},
 		{
 			Pattern: "/remove/",
@@ -81,31 +209,63 @@ func configure(h handler) []route {
 			Handler: http.HandlerFunc(h.RemoveHandler),
 		},
 		{
-			Pattern: "/shareText/",
+			Pattern:       "/shareText/",
+			Methods:       "POST",
+			CreateStorage: true,
+			Handler:       http.HandlerFunc(h.ShareTextHandler),
+		},
+		{
+			Pattern: "/admin/storages/orphaned/",
+			Methods: "GET",
+			Admin:   true,
+			Handler: http.HandlerFunc(h.OrphanedStoragesHandler),
+		},
+		{
+			Pattern: "/admin/storages/remove/",
 			Methods: "POST",
-			Handler: http.HandlerFunc(h.ShareTextHandler),
+			Admin:   true,
+			Handler: http.HandlerFunc(h.RemoveOrphanedStorageHandler),
 		},
 	}
 }
 
-func storeParametes(isPublic bool, next http.Handler) http.Handler {
+// formValue doesn't parse multipart body, it's streamed by handler
+// so parameters of multipart requests are taken from query
+func formValue(r *http.Request, key string) string {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return r.URL.Query().Get(key)
+	}
+	return r.FormValue(key)
+}
+
+// storeParametes stores storage and file of request in context
+// public storage flag is set by auth middleware for users without password only
+func storeParametes(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		storage := r.FormValue("storage")
+		storage := formValue(r, "storage")
 		if storage != "" {
+			storage, err := names.NormalizeStorage(storage)
+			if err != nil {
+				httperror.NewInvalidParams("invalid storage name").WithError(err).WriteJSON(w)
+				return
+			}
 			ctx = ctxinfo.WithUserName(ctx, storage)
 
-			permanent := r.FormValue("permanent")
+			permanent := formValue(r, "permanent")
 			if permanent != "" {
 				ctx = ctxinfo.WithPermanentStorage(ctx, true)
 			}
 		}
 
-		ctx = ctxinfo.WithPublicStorage(ctx, isPublic)
-
-		fileName := r.FormValue("file")
+		fileName := formValue(r, "file")
 		if fileName != "" {
+			fileName, err := names.NormalizeFile(fileName)
+			if err != nil {
+				httperror.NewInvalidParams("invalid file name").WithError(err).WriteJSON(w)
+				return
+			}
 			ctx = ctxinfo.WithFileName(ctx, fileName)
 		}
 		r = r.WithContext(ctx)
@@ -114,24 +274,50 @@ func storeParametes(isPublic bool, next http.Handler) http.Handler {
 	})
 }
 
+// chain wraps route handler with middlewares
+func chain(r route, authEnabled bool, h handler) http.Handler {
+	handler := r.Handler
+	if r.CreateStorage {
+		handler = h.CreateStorageMiddleware(handler)
+	}
+
+	if authEnabled && r.RequireMFA {
+		handler = h.RequireMFAMiddleware(handler)
+	}
+
+	if r.Admin {
+		handler = h.AdminMiddleware(handler)
+	} else if authEnabled && !r.Public {
+		handler = h.CheckAuthMiddleware(handler)
+	}
+
+	return storeParametes(handler)
+}
+
+// MakeRoutes registers legacy routes and versioned api with openapi document
 func MakeRoutes(router *mux.Router, authEnabled bool, h handler, l Logger) {
 	for _, route := range configure(h) {
 		muxRoute := router.NewRoute()
 		muxRoute.Path(route.Pattern)
-
 		muxRoute.Methods(strings.Split(route.Methods, ",")...)
+		muxRoute.Handler(h.RecoverMiddleware(chain(route, authEnabled, h)))
+	}
 
-		handler := route.Handler
-		if authEnabled && !route.Public {
-			handler = h.CheckAuthMiddleware(handler)
-		}
-
-		handler = h.CreateStorageMiddleware(handler)
-
-		handler = storeParametes(route.Public, handler)
+	api := router.PathPrefix(apiPrefix).Subrouter()
+	routes := configureAPI(h)
+	for _, ar := range routes {
+		handler := apiResult(ar, apiParameters(ar, chain(ar.route, authEnabled, h)))
 
-		handler = h.RecoverMiddleware(handler)
+		muxRoute := api.NewRoute()
+		muxRoute.Path(ar.Pattern)
+		muxRoute.Methods(strings.Split(ar.Methods, ",")...)
+		muxRoute.Handler(h.RecoverMiddleware(handler))
+	}
 
-		muxRoute.Handler(handler)
+	doc, err := openAPIHandler(routes)
+	if err != nil {
+		l.Errorf("unable to generate openapi document: %v", err)
+		return
 	}
+	api.Path("/openapi.json").Methods("GET").Handler(doc)
 }
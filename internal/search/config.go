@@ -0,0 +1,41 @@
+package search
+
+import (
+	"fmt"
+)
+
+// Config full text search configuration
+// max_terms caps index size, least recently used storages are evicted, zero disables cap
+// index_wait is time in seconds search waits for storage indexing
+// snapshot is optional file used to keep index between restarts
+type Config struct {
+	MaxFileSize int64  `yaml:"max_file_size"`
+	MaxTerms    int    `yaml:"max_terms"`
+	IndexWait   int    `yaml:"index_wait"`
+	Snapshot    string `yaml:"snapshot"`
+}
+
+// DefaultConfig used for omitted configuration values
+func DefaultConfig() Config {
+	return Config{
+		MaxFileSize: 1 << 20,
+		MaxTerms:    5000000,
+		IndexWait:   10,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.MaxFileSize <= 0 {
+		return fmt.Errorf("invalid max file size: %d", c.MaxFileSize)
+	}
+
+	if c.MaxTerms < 0 {
+		return fmt.Errorf("invalid max terms: %d", c.MaxTerms)
+	}
+
+	if c.IndexWait < 0 {
+		return fmt.Errorf("invalid index wait: %d", c.IndexWait)
+	}
+
+	return nil
+}
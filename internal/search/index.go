@@ -0,0 +1,324 @@
+package search
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	minTokenLength = 2
+	maxTokenLength = 64
+)
+
+// ErrIndexFull file doesn't fit into index even after other storages are evicted
+var ErrIndexFull = errors.New("search index is full")
+
+// docKey identifies single file in index
+type docKey struct {
+	Storage   string
+	Permanent bool
+	Name      string
+}
+
+type storageKey struct {
+	Storage   string
+	Permanent bool
+}
+
+// Index in-memory inverted index of text file contents
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[docKey]struct{}
+	docs     map[docKey][]string
+	indexed  map[storageKey]bool
+	used     map[storageKey]time.Time
+	terms    int
+	maxTerms int
+}
+
+// NewIndex constructor for Index
+// maxTerms caps total amount of terms of all files, least recently used storages are evicted, zero disables cap
+func NewIndex(maxTerms int) *Index {
+	return &Index{
+		postings: make(map[string]map[docKey]struct{}),
+		docs:     make(map[docKey][]string),
+		indexed:  make(map[storageKey]bool),
+		used:     make(map[storageKey]time.Time),
+		maxTerms: maxTerms,
+	}
+}
+
+// Tokenize splits text into lower case terms
+func Tokenize(text string) []string {
+	seen := make(map[string]struct{})
+	terms := make([]string, 0)
+	for _, f := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(f) < minTokenLength || len(f) > maxTokenLength {
+			continue
+		}
+
+		if _, ok := seen[f]; ok {
+			continue
+		}
+		seen[f] = struct{}{}
+		terms = append(terms, f)
+	}
+
+	return terms
+}
+
+// Add indexes file content replacing previous one
+func (idx *Index) Add(storage string, permanent bool, name string, content string) error {
+	key := docKey{Storage: storage, Permanent: permanent, Name: name}
+	sk := storageKey{Storage: storage, Permanent: permanent}
+	terms := Tokenize(content)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(key)
+
+	if !idx.evict(sk, len(terms)) {
+		return ErrIndexFull
+	}
+
+	for _, t := range terms {
+		p, ok := idx.postings[t]
+		if !ok {
+			p = make(map[docKey]struct{})
+			idx.postings[t] = p
+		}
+		p[key] = struct{}{}
+	}
+	idx.docs[key] = terms
+	idx.terms += len(terms)
+	idx.used[sk] = time.Now()
+
+	return nil
+}
+
+// evict removes least recently used storages except keep until need terms fit into index
+// should be called under lock
+func (idx *Index) evict(keep storageKey, need int) bool {
+	if idx.maxTerms <= 0 {
+		return true
+	}
+
+	for idx.terms+need > idx.maxTerms {
+		var (
+			victim storageKey
+			oldest time.Time
+			found  bool
+		)
+		for sk, t := range idx.used {
+			if sk == keep {
+				continue
+			}
+
+			if !found || t.Before(oldest) {
+				victim, oldest, found = sk, t, true
+			}
+		}
+
+		if !found {
+			return false
+		}
+
+		idx.removeStorage(victim)
+	}
+
+	return true
+}
+
+// Remove drops file from index
+func (idx *Index) Remove(storage string, permanent bool, name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(docKey{Storage: storage, Permanent: permanent, Name: name})
+}
+
+// RemoveStorage drops all storage files from index
+func (idx *Index) RemoveStorage(storage string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeStorage(storageKey{Storage: storage, Permanent: false})
+	idx.removeStorage(storageKey{Storage: storage, Permanent: true})
+}
+
+// Reset drops storage files and marks storage as not indexed
+func (idx *Index) Reset(storage string, permanent bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeStorage(storageKey{Storage: storage, Permanent: permanent})
+}
+
+// removeStorage should be called under lock
+func (idx *Index) removeStorage(sk storageKey) {
+	for key := range idx.docs {
+		if key.Storage == sk.Storage && key.Permanent == sk.Permanent {
+			idx.remove(key)
+		}
+	}
+
+	delete(idx.indexed, sk)
+	delete(idx.used, sk)
+}
+
+// remove should be called under lock
+func (idx *Index) remove(key docKey) {
+	idx.terms -= len(idx.docs[key])
+	for _, t := range idx.docs[key] {
+		p := idx.postings[t]
+		delete(p, key)
+		if len(p) == 0 {
+			delete(idx.postings, t)
+		}
+	}
+	delete(idx.docs, key)
+}
+
+// Search returns names of files containing all query terms
+func (idx *Index) Search(storage string, permanent bool, query string) []string {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	sk := storageKey{Storage: storage, Permanent: permanent}
+	if _, ok := idx.used[sk]; ok {
+		idx.used[sk] = time.Now()
+	}
+
+	var names []string
+	for key := range idx.postings[terms[0]] {
+		if key.Storage != storage || key.Permanent != permanent {
+			continue
+		}
+
+		matched := true
+		for _, t := range terms[1:] {
+			if _, ok := idx.postings[t][key]; !ok {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			names = append(names, key.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// IsIndexed checks whether all storage files were indexed
+func (idx *Index) IsIndexed(storage string, permanent bool) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.indexed[storageKey{Storage: storage, Permanent: permanent}]
+}
+
+// SetIndexed marks storage as fully indexed
+func (idx *Index) SetIndexed(storage string, permanent bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.indexed[storageKey{Storage: storage, Permanent: permanent}] = true
+}
+
+type snapshot struct {
+	Docs    map[docKey][]string
+	Indexed map[storageKey]bool
+}
+
+// Save stores index snapshot into file
+func (idx *Index) Save(path string) error {
+	idx.mu.RLock()
+	s := snapshot{
+		Docs:    idx.docs,
+		Indexed: idx.indexed,
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		idx.mu.RUnlock()
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+
+	err = gob.NewEncoder(f).Encode(s)
+	idx.mu.RUnlock()
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Load restores index from snapshot file, missing file is not an error
+func (idx *Index) Load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	var s snapshot
+	if err := gob.NewDecoder(f).Decode(&s); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.postings = make(map[string]map[docKey]struct{})
+	idx.docs = make(map[docKey][]string, len(s.Docs))
+	idx.indexed = s.Indexed
+	if idx.indexed == nil {
+		idx.indexed = make(map[storageKey]bool)
+	}
+	idx.used = make(map[storageKey]time.Time)
+	idx.terms = 0
+
+	now := time.Now()
+	for key, terms := range s.Docs {
+		idx.docs[key] = terms
+		idx.terms += len(terms)
+		idx.used[storageKey{Storage: key.Storage, Permanent: key.Permanent}] = now
+		for _, t := range terms {
+			p, ok := idx.postings[t]
+			if !ok {
+				p = make(map[docKey]struct{})
+				idx.postings[t] = p
+			}
+			p[key] = struct{}{}
+		}
+	}
+
+	return nil
+}
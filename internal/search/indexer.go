@@ -0,0 +1,255 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+)
+
+var textExtensions = map[string]bool{
+	"":      true,
+	".txt":  true,
+	".md":   true,
+	".csv":  true,
+	".json": true,
+	".xml":  true,
+	".yml":  true,
+	".yaml": true,
+	".log":  true,
+	".html": true,
+	".htm":  true,
+	".ini":  true,
+	".conf": true,
+}
+
+type Filer interface {
+	Files(storage string, isPermanent bool) ([]*file.File, error)
+	Get(storage string, isPermanent bool, fileName string, w io.Writer) error
+}
+
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// ErrIndexing storage is still being indexed, search should be retried later
+var ErrIndexing = errors.New("storage is being indexed")
+
+// job background indexing of single storage
+type job struct {
+	done  chan struct{}
+	again bool
+	err   error
+}
+
+// Indexer keeps index current with storage files
+type Indexer struct {
+	index   *Index
+	file    Filer
+	logger  Logger
+	maxSize int64
+	wait    time.Duration
+
+	mu   sync.Mutex
+	jobs map[storageKey]*job
+}
+
+// NewIndexer constructor for Indexer
+// files bigger than maxSize are not indexed, search waits for storage indexing no longer than wait
+func NewIndexer(idx *Index, f Filer, l Logger, maxSize int64, wait time.Duration) *Indexer {
+	return &Indexer{
+		index:   idx,
+		file:    f,
+		logger:  l,
+		maxSize: maxSize,
+		wait:    wait,
+		jobs:    make(map[storageKey]*job),
+	}
+}
+
+// IsText checks whether file is indexed by its name
+func IsText(name string) bool {
+	return textExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// limitedWriter fails when more than limit bytes written
+type limitedWriter struct {
+	buf   bytes.Buffer
+	limit int64
+}
+
+var errTooLarge = errors.New("file is too large for indexing")
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if int64(lw.buf.Len()+len(p)) > lw.limit {
+		return 0, errTooLarge
+	}
+	return lw.buf.Write(p)
+}
+
+// IndexFile fetches file content and adds it into index
+func (i *Indexer) IndexFile(storage string, permanent bool, name string) error {
+	if !IsText(name) {
+		return nil
+	}
+
+	lw := limitedWriter{limit: i.maxSize}
+	if err := i.file.Get(storage, permanent, name, &lw); err != nil {
+		if errors.Is(err, errTooLarge) {
+			i.index.Remove(storage, permanent, name)
+			return nil
+		}
+		return fmt.Errorf("get file %s: %w", name, err)
+	}
+
+	content := lw.buf.Bytes()
+	if !utf8.Valid(content) {
+		i.index.Remove(storage, permanent, name)
+		return nil
+	}
+
+	if err := i.index.Add(storage, permanent, name, string(content)); err != nil {
+		return fmt.Errorf("add file %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// IndexStorage indexes all text files of storage
+// file failed to index is skipped, so it isn't found until the next upload instead of disabling search of storage
+func (i *Indexer) IndexStorage(storage string, permanent bool) error {
+	files, err := i.file.Files(storage, permanent)
+	if err != nil {
+		return fmt.Errorf("get files: %w", err)
+	}
+
+	for _, f := range files {
+		if f.GetSize() > i.maxSize {
+			continue
+		}
+
+		if err := i.IndexFile(storage, permanent, f.GetName()); err != nil {
+			i.logger.Errorf("unable to index file %s of storage %s: %v", f.GetName(), storage, err)
+		}
+	}
+
+	i.index.SetIndexed(storage, permanent)
+	return nil
+}
+
+// indexInBackground starts storage indexing job or joins running one
+// restart makes running job index storage once more to pick up changes made meanwhile
+func (i *Indexer) indexInBackground(storage string, permanent bool, restart bool) *job {
+	key := storageKey{Storage: storage, Permanent: permanent}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if j, ok := i.jobs[key]; ok {
+		if restart {
+			j.again = true
+		}
+		return j
+	}
+
+	j := &job{done: make(chan struct{})}
+	i.jobs[key] = j
+	go i.run(key, j)
+
+	return j
+}
+
+func (i *Indexer) run(key storageKey, j *job) {
+	for {
+		i.index.Reset(key.Storage, key.Permanent)
+		err := i.IndexStorage(key.Storage, key.Permanent)
+		if err != nil {
+			i.index.Reset(key.Storage, key.Permanent)
+			i.logger.Errorf("unable to index storage %s: %v", key.Storage, err)
+		}
+
+		i.mu.Lock()
+		if err == nil && j.again {
+			j.again = false
+			i.mu.Unlock()
+			continue
+		}
+
+		j.err = err
+		delete(i.jobs, key)
+		i.mu.Unlock()
+
+		close(j.done)
+		return
+	}
+}
+
+// Search returns names of files containing all query terms
+// storage is indexed in background on the first search, ErrIndexing is returned if it takes longer than wait
+func (i *Indexer) Search(storage string, permanent bool, query string) ([]string, error) {
+	if !i.index.IsIndexed(storage, permanent) {
+		j := i.indexInBackground(storage, permanent, false)
+
+		t := time.NewTimer(i.wait)
+		defer t.Stop()
+
+		select {
+		case <-j.done:
+			if j.err != nil {
+				return nil, fmt.Errorf("index storage %s: %w", storage, j.err)
+			}
+		case <-t.C:
+			return nil, ErrIndexing
+		}
+	}
+
+	return i.index.Search(storage, permanent, query), nil
+}
+
+// HandleFileEvent event.FileEvent subscriber
+func (i *Indexer) HandleFileEvent(ctx context.Context, e *event.FileEvent) error {
+	if !i.index.IsIndexed(e.GetUserName(), e.GetIsPermanent()) {
+		switch e.GetAction() {
+		case event.Action_Add, event.Action_Restore, event.Action_VersionRestore:
+			// storage is indexed on upload so the first search doesn't wait for it
+			i.indexInBackground(e.GetUserName(), e.GetIsPermanent(), true)
+		}
+		return nil
+	}
+
+	switch e.GetAction() {
+	case event.Action_Add, event.Action_Restore, event.Action_VersionRestore:
+		if e.GetSize() > i.maxSize {
+			i.index.Remove(e.GetUserName(), e.GetIsPermanent(), e.GetFileName())
+			return nil
+		}
+
+		if err := i.IndexFile(e.GetUserName(), e.GetIsPermanent(), e.GetFileName()); err != nil {
+			i.logger.Errorf("unable to index file %s: %v", e.GetFileName(), err)
+		}
+
+	case event.Action_Remove, event.Action_Expired:
+		i.index.Remove(e.GetUserName(), e.GetIsPermanent(), e.GetFileName())
+	}
+
+	return nil
+}
+
+// HandleAccountEvent event.AccountEvent subscriber
+func (i *Indexer) HandleAccountEvent(ctx context.Context, e *event.AccountEvent) error {
+	if e.GetAction() == event.AccountAction_Deleted {
+		i.index.RemoveStorage(e.GetUserName())
+	}
+
+	return nil
+}
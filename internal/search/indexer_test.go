@@ -0,0 +1,146 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+)
+
+var errBroken = errors.New("broken file")
+
+// fakeFiler serves storage files from memory, files with nil content fail on get
+type fakeFiler struct {
+	files map[string][]byte
+}
+
+func (f *fakeFiler) Files(storage string, isPermanent bool) ([]*file.File, error) {
+	if storage == "unavailable" {
+		return nil, errors.New("service unavailable")
+	}
+
+	files := make([]*file.File, 0, len(f.files))
+	for name, content := range f.files {
+		files = append(files, &file.File{Name: name, Size: int64(len(content))})
+	}
+	return files, nil
+}
+
+func (f *fakeFiler) Get(storage string, isPermanent bool, fileName string, w io.Writer) error {
+	content := f.files[fileName]
+	if content == nil {
+		return errBroken
+	}
+
+	_, err := w.Write(content)
+	return err
+}
+
+type testLogger struct {
+	errors []string
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {}
+
+func (l *testLogger) Errorf(format string, args ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+func newTestIndexer(files map[string][]byte) (*Indexer, *fakeFiler, *testLogger) {
+	f := fakeFiler{files: files}
+	l := testLogger{}
+	return NewIndexer(NewIndex(0), &f, &l, 32, time.Second), &f, &l
+}
+
+func TestIndexStorage(t *testing.T) {
+	i, _, l := newTestIndexer(map[string][]byte{
+		"a.txt":      []byte("hello world"),
+		"b.md":       []byte("hello again"),
+		"broken.txt": nil,
+		"image.png":  []byte("hello png"),
+		"binary.txt": []byte("hello \xff"),
+		"large.txt":  []byte("hello from file which is too large for indexing"),
+	})
+
+	if err := i.IndexStorage("alice", true); err != nil {
+		t.Fatalf("index storage: %v", err)
+	}
+
+	if !i.index.IsIndexed("alice", true) {
+		t.Error("storage is not marked as indexed")
+	}
+
+	if names := i.index.Search("alice", true, "hello"); !reflect.DeepEqual(names, []string{"a.txt", "b.md"}) {
+		t.Errorf("unexpected search result: %v", names)
+	}
+
+	if len(l.errors) != 1 {
+		t.Errorf("expected error of broken file only, got %v", l.errors)
+	}
+}
+
+func TestIndexStorageFilesError(t *testing.T) {
+	i, _, _ := newTestIndexer(map[string][]byte{})
+
+	if err := i.IndexStorage("unavailable", false); err == nil {
+		t.Fatal("files error is not returned")
+	}
+
+	if i.index.IsIndexed("unavailable", false) {
+		t.Error("storage is marked as indexed")
+	}
+}
+
+func TestIndexFileGrownTooLarge(t *testing.T) {
+	i, f, _ := newTestIndexer(map[string][]byte{
+		"a.txt": []byte("hello"),
+	})
+
+	if err := i.IndexFile("alice", false, "a.txt"); err != nil {
+		t.Fatalf("index file: %v", err)
+	}
+
+	f.files["a.txt"] = []byte("hello from file which is too large for indexing")
+	if err := i.IndexFile("alice", false, "a.txt"); err != nil {
+		t.Fatalf("index large file: %v", err)
+	}
+
+	if names := i.index.Search("alice", false, "hello"); len(names) != 0 {
+		t.Errorf("large file is left in index: %v", names)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	i, f, _ := newTestIndexer(map[string][]byte{
+		"a.txt":      []byte("hello world"),
+		"broken.txt": nil,
+	})
+
+	names, err := i.Search("alice", false, "world")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"a.txt"}) {
+		t.Errorf("unexpected search result: %v", names)
+	}
+
+	f.files["b.txt"] = []byte("new world")
+	i.HandleFileEvent(context.Background(), &event.FileEvent{UserName: "alice", FileName: "b.txt", Size: 9, Action: event.Action_Add})
+	i.HandleFileEvent(context.Background(), &event.FileEvent{UserName: "alice", FileName: "a.txt", Action: event.Action_Remove})
+
+	names, err = i.Search("alice", false, "world")
+	if err != nil {
+		t.Fatalf("search after events: %v", err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"b.txt"}) {
+		t.Errorf("unexpected search result after events: %v", names)
+	}
+}
@@ -0,0 +1,132 @@
+// Package storages keeps local cache of existing storages
+// so gateway doesn't ask file service about storage on every request
+package storages
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+)
+
+// Config storage cache configuration
+// ttl is in seconds, storage is checked again after it expires
+type Config struct {
+	TTL        int `yaml:"ttl"`
+	MaxEntries int `yaml:"max_entries"`
+}
+
+// DefaultConfig used for omitted configuration values
+func DefaultConfig() Config {
+	return Config{
+		TTL:        300,
+		MaxEntries: 100000,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.TTL <= 0 {
+		return fmt.Errorf("invalid ttl: %d", c.TTL)
+	}
+
+	if c.MaxEntries <= 0 {
+		return fmt.Errorf("invalid max entries: %d", c.MaxEntries)
+	}
+
+	return nil
+}
+
+// Cache known storages with expiration time
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]time.Time
+}
+
+func NewCache(c Config) *Cache {
+	return &Cache{
+		ttl:        time.Duration(c.TTL) * time.Second,
+		maxEntries: c.MaxEntries,
+		entries:    make(map[string]time.Time),
+	}
+}
+
+// Known checks whether storage is known to exist
+func (c *Cache) Known(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.entries[name]
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(expires) {
+		delete(c.entries, name)
+		return false
+	}
+
+	return true
+}
+
+// Add marks storage as existing
+func (c *Cache) Add(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[name]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+
+	c.entries[name] = time.Now().Add(c.ttl)
+}
+
+// evict removes expired entries, arbitrary one is removed if there are no expired
+func (c *Cache) evict() {
+	now := time.Now()
+	for name, expires := range c.entries {
+		if now.After(expires) {
+			delete(c.entries, name)
+		}
+	}
+
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+
+	for name := range c.entries {
+		delete(c.entries, name)
+		return
+	}
+}
+
+func (c *Cache) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, name)
+}
+
+// HandleStorageEvent event.StorageEvent subscriber
+func (c *Cache) HandleStorageEvent(ctx context.Context, e *event.StorageEvent) error {
+	switch e.GetAction() {
+	case event.StorageAction_StorageCreated:
+		c.Add(e.GetName())
+	case event.StorageAction_StorageDeleted:
+		c.Remove(e.GetName())
+	}
+
+	return nil
+}
+
+// HandleAccountEvent event.AccountEvent subscriber
+func (c *Cache) HandleAccountEvent(ctx context.Context, e *event.AccountEvent) error {
+	if e.GetAction() == event.AccountAction_Deleted {
+		c.Remove(e.GetUserName())
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package conflict
+
+import (
+	"fmt"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+)
+
+// ParsePolicy parses conflict policy name, empty name means overwrite
+// with rename policy file service picks free name like "file (1).txt" itself
+func ParsePolicy(s string) (file.ConflictPolicy, error) {
+	switch s {
+	case "", "overwrite":
+		return file.ConflictPolicy_ConflictOverwrite, nil
+	case "rename":
+		return file.ConflictPolicy_ConflictRename, nil
+	case "fail":
+		return file.ConflictPolicy_ConflictFail, nil
+	}
+
+	return file.ConflictPolicy_ConflictOverwrite, fmt.Errorf("invalid conflict policy: %s", s)
+}
@@ -13,6 +13,9 @@ const (
 	contextPermanentStorage = contextInfoKey("contextPermanentStorage")
 	contextFileName         = contextInfoKey("contextFileName")
 	contextPublicStorage    = contextInfoKey("contextPublicStorage")
+	contextMFAVerified      = contextInfoKey("contextMFAVerified")
+	contextMFAEnrolled      = contextInfoKey("contextMFAEnrolled")
+	contextRequestID        = contextInfoKey("contextRequestID")
 )
 
 var (
@@ -108,3 +111,57 @@ func PublicStorage(ctx context.Context) (bool, error) {
 
 	return public, nil
 }
+
+func WithMFAVerified(ctx context.Context, verified bool) context.Context {
+	return context.WithValue(ctx, contextMFAVerified, verified)
+}
+
+func MFAVerified(ctx context.Context) (bool, error) {
+	v := ctx.Value(contextMFAVerified)
+	if v == nil {
+		return false, ErrNotFound
+	}
+
+	verified, ok := v.(bool)
+	if !ok {
+		return false, errors.New("mfa verified is not bool")
+	}
+
+	return verified, nil
+}
+
+func WithMFAEnrolled(ctx context.Context, enrolled bool) context.Context {
+	return context.WithValue(ctx, contextMFAEnrolled, enrolled)
+}
+
+func MFAEnrolled(ctx context.Context) (bool, error) {
+	v := ctx.Value(contextMFAEnrolled)
+	if v == nil {
+		return false, ErrNotFound
+	}
+
+	enrolled, ok := v.(bool)
+	if !ok {
+		return false, errors.New("mfa enrolled is not bool")
+	}
+
+	return enrolled, nil
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextRequestID, id)
+}
+
+func RequestID(ctx context.Context) (string, error) {
+	v := ctx.Value(contextRequestID)
+	if v == nil {
+		return "", ErrNotFound
+	}
+
+	id, ok := v.(string)
+	if !ok {
+		return "", errors.New("request id is not string")
+	}
+
+	return id, nil
+}
@@ -1,17 +1,41 @@
 package httperror
 
+import (
+	"net/http"
+)
+
 type Code int
 
 const (
-	CodeNoError       Code = 0
-	CodeInternalError Code = 1
-	CodeInvalidParams Code = 2
-	CodeUnauthorized  Code = 3
-	CodeAlreadyExist  Code = 4
-	CodeNotExist      Code = 5
-	CodeNotMatch      Code = 6
+	CodeNoError         Code = 0
+	CodeInternalError   Code = 1
+	CodeInvalidParams   Code = 2
+	CodeUnauthorized    Code = 3
+	CodeAlreadyExist    Code = 4
+	CodeNotExist        Code = 5
+	CodeNotMatch        Code = 6
+	CodeTooManyRequests Code = 7
+	CodeTooLarge        Code = 8
+	CodeForbidden       Code = 9
+	CodeUnavailable     Code = 10
 )
 
 func (c Code) Int() int {
 	return int(c)
 }
+
+// HTTPStatus returns http status code for error code
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeTooManyRequests:
+		return http.StatusTooManyRequests
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusBadRequest
+}
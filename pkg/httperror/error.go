@@ -43,7 +43,7 @@ func (e *Error) Unwrap() error {
 
 func (e *Error) WriteJSON(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(e.Code.HTTPStatus())
 	return json.NewEncoder(w).Encode(e)
 }
 
@@ -70,3 +70,19 @@ func NewNotExistError(description string) *Error {
 func NewNotMatchError(description string) *Error {
 	return New(CodeNotMatch, description)
 }
+
+func NewTooManyRequests(description string) *Error {
+	return New(CodeTooManyRequests, description)
+}
+
+func NewTooLarge(description string) *Error {
+	return New(CodeTooLarge, description)
+}
+
+func NewForbidden(description string) *Error {
+	return New(CodeForbidden, description)
+}
+
+func NewUnavailable(description string) *Error {
+	return New(CodeUnavailable, description)
+}
@@ -0,0 +1,108 @@
+package jwks
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ParsePEM parses pem encoded rsa public key
+func ParsePEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block containing rsa public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key: %w", err)
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not a rsa public key: %T", key)
+	}
+
+	return pub, nil
+}
+
+func kidFromFileName(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+}
+
+// LoadPath loads pem encoded public keys from file or directory
+// file name without extension is used as kid
+func LoadPath(path string) (map[string]*rsa.PublicKey, error) {
+	files, err := pemFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f, err)
+		}
+
+		pub, err := ParsePEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", f, err)
+		}
+
+		keys[kidFromFileName(f)] = pub
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", path)
+	}
+
+	return keys, nil
+}
+
+// PathModTime returns the latest modification time of key files
+// it's used to detect key changes
+func PathModTime(path string) (time.Time, error) {
+	files, err := pemFiles(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat %s: %w", f, err)
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+func pemFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", path, err)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,182 @@
+package jwks
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+// Loader loads current set of keys
+type Loader func() (map[string]*rsa.PublicKey, error)
+
+// Set keeps active verification keys and reloads them periodically
+type Set struct {
+	mu         sync.RWMutex
+	keys       map[string]*rsa.PublicKey
+	load       Loader
+	changed    func() (bool, error)
+	onError    func(error)
+	lastReload time.Time
+	minReload  time.Duration
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewSet loads keys and starts reloading every interval
+// changed is optional check whether keys should be reloaded
+// zero interval disables periodic reload
+func NewSet(load Loader, changed func() (bool, error), interval time.Duration, onError func(error)) (*Set, error) {
+	s := &Set{
+		load:      load,
+		changed:   changed,
+		onError:   onError,
+		minReload: 10 * time.Second,
+		stop:      make(chan struct{}),
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go s.watch(interval)
+	}
+
+	return s, nil
+}
+
+// NewLazySet creates set which loads keys on the first use
+// it's useful when keys are fetched from service which may be not started yet
+func NewLazySet(load Loader, interval time.Duration, onError func(error)) *Set {
+	s := &Set{
+		load:      load,
+		onError:   onError,
+		minReload: 10 * time.Second,
+		stop:      make(chan struct{}),
+	}
+
+	if interval > 0 {
+		go s.watch(interval)
+	}
+
+	return s
+}
+
+// NewStaticSet creates set with predefined keys without reloading
+func NewStaticSet(keys map[string]*rsa.PublicKey) *Set {
+	return &Set{
+		keys: keys,
+		stop: make(chan struct{}),
+	}
+}
+
+// Reload loads keys and replaces active ones
+func (s *Set) Reload() error {
+	keys, err := s.load()
+	if err != nil {
+		return fmt.Errorf("load keys: %w", err)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.lastReload = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Key returns key by kid
+// empty kid is allowed only when set contains single key
+// unknown kid triggers reload since keys may have been rotated
+func (s *Set) Key(kid string) (*rsa.PublicKey, error) {
+	if k, err := s.key(kid); err == nil {
+		return k, nil
+	}
+
+	if s.load == nil {
+		return s.key(kid)
+	}
+
+	s.mu.Lock()
+	canReload := (len(s.keys) == 0 || kid != "") && time.Since(s.lastReload) > s.minReload
+	if canReload {
+		s.lastReload = time.Now()
+	}
+	s.mu.Unlock()
+
+	if canReload {
+		if err := s.Reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.key(kid)
+}
+
+func (s *Set) key(kid string) (*rsa.PublicKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" {
+		if len(s.keys) == 1 {
+			for _, k := range s.keys {
+				return k, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: token without kid", ErrKeyNotFound)
+	}
+
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+
+	return k, nil
+}
+
+// Close stops reloading
+func (s *Set) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *Set) watch(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			if s.changed != nil {
+				changed, err := s.changed()
+				if err != nil {
+					s.reportError(err)
+					continue
+				}
+
+				if !changed {
+					continue
+				}
+			}
+
+			if err := s.Reload(); err != nil {
+				s.reportError(err)
+			}
+		}
+	}
+}
+
+func (s *Set) reportError(err error) {
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
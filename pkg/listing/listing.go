@@ -0,0 +1,176 @@
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	microerrors "github.com/asim/go-micro/v3/errors"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+)
+
+// InvalidCursorID rpc error id for invalid cursor, errors.Is doesn't work across service boundary
+const InvalidCursorID = "filesharing.listing.invalid_cursor"
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// RPCError converts invalid cursor error into rpc error to be returned by file service
+func RPCError(err error) error {
+	if errors.Is(err, ErrInvalidCursor) {
+		return microerrors.BadRequest(InvalidCursorID, "%s", err.Error())
+	}
+	return err
+}
+
+// IsInvalidCursor checks local or rpc error for invalid cursor
+func IsInvalidCursor(err error) bool {
+	if errors.Is(err, ErrInvalidCursor) {
+		return true
+	}
+
+	var e *microerrors.Error
+	return errors.As(err, &e) && e.Id == InvalidCursorID
+}
+
+// Cursor position of the last returned file
+// pagination is keyset based, so files added concurrently don't shift pages
+type Cursor struct {
+	Sort    file.SortField `json:"s"`
+	Desc    bool           `json:"d"`
+	Prefix  string         `json:"p"`
+	Name    string         `json:"n"`
+	Size    int64          `json:"sz"`
+	ModTime int64          `json:"mt"`
+}
+
+// EncodeCursor returns opaque cursor string
+func EncodeCursor(c Cursor) string {
+	buf, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// DecodeCursor parses cursor string
+func DecodeCursor(s string) (Cursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(buf, &c); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	return c, nil
+}
+
+// ParseSortField parses sort field name
+func ParseSortField(s string) (file.SortField, error) {
+	switch s {
+	case "", "name":
+		return file.SortField_SortByName, nil
+	case "size":
+		return file.SortField_SortBySize, nil
+	case "mod_time":
+		return file.SortField_SortByModTime, nil
+	}
+
+	return file.SortField_SortByName, fmt.Errorf("invalid sort field: %s", s)
+}
+
+// less compares files by sort field, name is used as tie breaker
+func less(a, b *file.File, field file.SortField) bool {
+	switch field {
+	case file.SortField_SortBySize:
+		if a.GetSize() != b.GetSize() {
+			return a.GetSize() < b.GetSize()
+		}
+	case file.SortField_SortByModTime:
+		if a.GetModTime() != b.GetModTime() {
+			return a.GetModTime() < b.GetModTime()
+		}
+	}
+
+	return a.GetName() < b.GetName()
+}
+
+func after(f *file.File, c Cursor) bool {
+	pivot := &file.File{
+		Name:    c.Name,
+		Size:    c.Size,
+		ModTime: c.ModTime,
+	}
+
+	if c.Desc {
+		return less(f, pivot, c.Sort)
+	}
+	return less(pivot, f, c.Sort)
+}
+
+// Sort sorts files by field in specified direction
+func Sort(files []*file.File, field file.SortField, desc bool) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if desc {
+			return less(files[j], files[i], field)
+		}
+		return less(files[i], files[j], field)
+	})
+}
+
+// Paginate filters, sorts and cuts single page of files according to request
+// zero page size returns all files
+func Paginate(files []*file.File, req *file.ListRequest) (*file.ListResponse, error) {
+	filtered := make([]*file.File, 0, len(files))
+	for _, f := range files {
+		if strings.HasPrefix(f.GetName(), req.GetPrefix()) {
+			filtered = append(filtered, f)
+		}
+	}
+
+	Sort(filtered, req.GetSort(), req.GetDesc())
+
+	start := 0
+	if req.GetCursor() != "" {
+		c, err := DecodeCursor(req.GetCursor())
+		if err != nil {
+			return nil, err
+		}
+
+		if c.Sort != req.GetSort() || c.Desc != req.GetDesc() || c.Prefix != req.GetPrefix() {
+			return nil, fmt.Errorf("%w: cursor doesn't match request", ErrInvalidCursor)
+		}
+
+		start = sort.Search(len(filtered), func(i int) bool {
+			return after(filtered[i], c)
+		})
+	}
+
+	rsp := &file.ListResponse{
+		Total:     int64(len(filtered)),
+		Paginated: true,
+	}
+
+	end := len(filtered)
+	if req.GetPageSize() > 0 && start+int(req.GetPageSize()) < end {
+		end = start + int(req.GetPageSize())
+
+		last := filtered[end-1]
+		rsp.NextCursor = EncodeCursor(Cursor{
+			Sort:    req.GetSort(),
+			Desc:    req.GetDesc(),
+			Prefix:  req.GetPrefix(),
+			Name:    last.GetName(),
+			Size:    last.GetSize(),
+			ModTime: last.GetModTime(),
+		})
+	}
+
+	rsp.Files = filtered[start:end]
+	return rsp, nil
+}
@@ -0,0 +1,193 @@
+package listing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+)
+
+func testFiles() []*file.File {
+	return []*file.File{
+		{Name: "a.txt", Size: 10, ModTime: 3},
+		{Name: "b.txt", Size: 5, ModTime: 1},
+		{Name: "c.txt", Size: 10, ModTime: 2},
+		{Name: "d.log", Size: 5, ModTime: 3},
+		{Name: "e.txt", Size: 1, ModTime: 3},
+	}
+}
+
+func names(files []*file.File) []string {
+	n := make([]string, 0, len(files))
+	for _, f := range files {
+		n = append(n, f.GetName())
+	}
+	return n
+}
+
+// pages requests pages following next cursor until the last one
+// total of every page should be amount of files on all pages
+func pages(t *testing.T, files []*file.File, req *file.ListRequest) [][]string {
+	t.Helper()
+
+	var (
+		result [][]string
+		totals []int64
+		count  int64
+	)
+	for {
+		rsp, err := Paginate(files, req)
+		if err != nil {
+			t.Fatalf("paginate: %v", err)
+		}
+
+		result = append(result, names(rsp.GetFiles()))
+		totals = append(totals, rsp.GetTotal())
+		count += int64(len(rsp.GetFiles()))
+
+		if rsp.GetNextCursor() == "" {
+			break
+		}
+		req.Cursor = rsp.GetNextCursor()
+	}
+
+	for _, total := range totals {
+		if total != count {
+			t.Fatalf("expected total %d, got %d", count, total)
+		}
+	}
+
+	return result
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{},
+		{Sort: file.SortField_SortByName, Name: "a.txt"},
+		{Sort: file.SortField_SortBySize, Desc: true, Prefix: "doc", Name: "doc 1.txt", Size: 1 << 40},
+		{Sort: file.SortField_SortByModTime, Name: "файл.txt", ModTime: 1640995200},
+	}
+
+	for _, c := range tests {
+		decoded, err := DecodeCursor(EncodeCursor(c))
+		if err != nil {
+			t.Fatalf("decode cursor %+v: %v", c, err)
+		}
+
+		if decoded != c {
+			t.Errorf("expected cursor %+v, got %+v", c, decoded)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *file.ListRequest
+		expected [][]string
+	}{
+		{
+			name:     "all files",
+			req:      &file.ListRequest{},
+			expected: [][]string{{"a.txt", "b.txt", "c.txt", "d.log", "e.txt"}},
+		},
+		{
+			name:     "name",
+			req:      &file.ListRequest{PageSize: 2},
+			expected: [][]string{{"a.txt", "b.txt"}, {"c.txt", "d.log"}, {"e.txt"}},
+		},
+		{
+			name:     "name desc",
+			req:      &file.ListRequest{PageSize: 2, Desc: true},
+			expected: [][]string{{"e.txt", "d.log"}, {"c.txt", "b.txt"}, {"a.txt"}},
+		},
+		{
+			name:     "size ties ordered by name",
+			req:      &file.ListRequest{PageSize: 2, Sort: file.SortField_SortBySize},
+			expected: [][]string{{"e.txt", "b.txt"}, {"d.log", "a.txt"}, {"c.txt"}},
+		},
+		{
+			name:     "size desc ties ordered by name desc",
+			req:      &file.ListRequest{PageSize: 2, Sort: file.SortField_SortBySize, Desc: true},
+			expected: [][]string{{"c.txt", "a.txt"}, {"d.log", "b.txt"}, {"e.txt"}},
+		},
+		{
+			name:     "mod time ties split across pages",
+			req:      &file.ListRequest{PageSize: 3, Sort: file.SortField_SortByModTime},
+			expected: [][]string{{"b.txt", "c.txt", "a.txt"}, {"d.log", "e.txt"}},
+		},
+		{
+			name:     "mod time desc",
+			req:      &file.ListRequest{PageSize: 4, Sort: file.SortField_SortByModTime, Desc: true},
+			expected: [][]string{{"e.txt", "d.log", "a.txt", "c.txt"}, {"b.txt"}},
+		},
+		{
+			name:     "prefix",
+			req:      &file.ListRequest{PageSize: 1, Prefix: "d"},
+			expected: [][]string{{"d.log"}},
+		},
+		{
+			name:     "page size equal to files",
+			req:      &file.ListRequest{PageSize: 5},
+			expected: [][]string{{"a.txt", "b.txt", "c.txt", "d.log", "e.txt"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if p := pages(t, testFiles(), tc.req); !reflect.DeepEqual(p, tc.expected) {
+				t.Errorf("expected pages %v, got %v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestPaginateFileAddedBeforeCursor(t *testing.T) {
+	files := testFiles()
+	rsp, err := Paginate(files, &file.ListRequest{PageSize: 2})
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+
+	files = append(files, &file.File{Name: "aa.txt"})
+	rsp, err = Paginate(files, &file.ListRequest{PageSize: 2, Cursor: rsp.GetNextCursor()})
+	if err != nil {
+		t.Fatalf("paginate next page: %v", err)
+	}
+
+	if n := names(rsp.GetFiles()); !reflect.DeepEqual(n, []string{"c.txt", "d.log"}) {
+		t.Errorf("next page is shifted: %v", n)
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	nameCursor := EncodeCursor(Cursor{Sort: file.SortField_SortByName, Name: "b.txt"})
+
+	tests := []struct {
+		name string
+		req  *file.ListRequest
+	}{
+		{"not base64", &file.ListRequest{Cursor: "!!!"}},
+		{"not json", &file.ListRequest{Cursor: "bm90IGpzb24"}},
+		{"other sort", &file.ListRequest{Cursor: nameCursor, Sort: file.SortField_SortBySize}},
+		{"other order", &file.ListRequest{Cursor: nameCursor, Desc: true}},
+		{"other prefix", &file.ListRequest{Cursor: nameCursor, Prefix: "b"}},
+	}
+
+	for _, tc := range tests {
+		_, err := Paginate(testFiles(), tc.req)
+		if !IsInvalidCursor(err) {
+			t.Errorf("%s: expected invalid cursor error, got %v", tc.name, err)
+		}
+
+		if !IsInvalidCursor(RPCError(err)) {
+			t.Errorf("%s: rpc error is not recognized: %v", tc.name, RPCError(err))
+		}
+	}
+
+	other := errors.New("other")
+	if IsInvalidCursor(other) || IsInvalidCursor(RPCError(other)) {
+		t.Error("other error is recognized as invalid cursor")
+	}
+}
@@ -0,0 +1,70 @@
+package mimetype
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// SniffLen amount of bytes used for content sniffing
+const SniffLen = 512
+
+// generic sniffed types are refined by file extension
+var generic = map[string]bool{
+	"application/octet-stream":     true,
+	"text/plain; charset=utf-8":    true,
+	"text/plain; charset=utf-16be": true,
+	"text/plain; charset=utf-16le": true,
+	"text/xml; charset=utf-8":      true,
+	"application/zip":              true,
+}
+
+// Detect returns mime type by content sniffing and file extension
+func Detect(name string, head []byte) string {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+
+	sniffed := http.DetectContentType(head)
+	if !generic[sniffed] {
+		return sniffed
+	}
+
+	if byExt := mime.TypeByExtension(strings.ToLower(path.Ext(name))); byExt != "" {
+		return byExt
+	}
+
+	return sniffed
+}
+
+// DetectReader detects mime type of reader content
+// returned reader should be used instead of original one
+func DetectReader(name string, r io.Reader) (string, io.Reader, error) {
+	br := bufio.NewReaderSize(r, SniffLen)
+	head, err := br.Peek(SniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", nil, err
+	}
+
+	return Detect(name, head), br, nil
+}
+
+// IsActive checks whether browser may execute scripts from content of such type
+func IsActive(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+
+	switch {
+	case t == "text/html", t == "application/xhtml+xml", t == "image/svg+xml":
+		return true
+	case t == "text/xml", t == "application/xml", strings.HasSuffix(t, "+xml"):
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooShort       = errors.New("password is too short")
+	ErrTooLong        = errors.New("password is too long")
+	ErrNoUpper        = errors.New("password should contain upper case letter")
+	ErrNoLower        = errors.New("password should contain lower case letter")
+	ErrNoDigit        = errors.New("password should contain digit")
+	ErrNoSpecial      = errors.New("password should contain special character")
+	ErrSameAsName     = errors.New("password should not be the same as storage name")
+	ErrInvalidSymbols = errors.New("password contains invalid symbols")
+)
+
+// Policy describes password requirements
+// it's checked by gateway on register, password change and reset
+type Policy struct {
+	MinLength      int  `yaml:"min_length"`
+	MaxLength      int  `yaml:"max_length"`
+	RequireUpper   bool `yaml:"require_upper"`
+	RequireLower   bool `yaml:"require_lower"`
+	RequireDigit   bool `yaml:"require_digit"`
+	RequireSpecial bool `yaml:"require_special"`
+}
+
+// DefaultPolicy used when policy is not configured
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength: 8,
+		MaxLength: 128,
+	}
+}
+
+func (p Policy) Validate() error {
+	if p.MinLength <= 0 {
+		return fmt.Errorf("invalid min length: %d", p.MinLength)
+	}
+
+	if p.MaxLength < p.MinLength {
+		return fmt.Errorf("max length %d is less than min length %d", p.MaxLength, p.MinLength)
+	}
+
+	return nil
+}
+
+// Check checks password against policy for specified storage name
+func (p Policy) Check(name, password string) error {
+	if !utf8.ValidString(password) {
+		return ErrInvalidSymbols
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: minimum length is %d", ErrTooShort, p.MinLength)
+	}
+
+	if length > p.MaxLength {
+		return fmt.Errorf("%w: maximum length is %d", ErrTooLong, p.MaxLength)
+	}
+
+	if name != "" && password == name {
+		return ErrSameAsName
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsControl(r):
+			return ErrInvalidSymbols
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSpecial = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		return ErrNoUpper
+	}
+
+	if p.RequireLower && !hasLower {
+		return ErrNoLower
+	}
+
+	if p.RequireDigit && !hasDigit {
+		return ErrNoDigit
+	}
+
+	if p.RequireSpecial && !hasSpecial {
+		return ErrNoSpecial
+	}
+
+	return nil
+}
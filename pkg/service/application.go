@@ -2,13 +2,21 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/asim/go-micro/v3"
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/cmd"
+	"github.com/asim/go-micro/v3/selector"
 	"github.com/asim/go-micro/v3/server"
+	"github.com/asim/go-micro/v3/transport"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -21,33 +29,77 @@ type Servicer interface {
 	Publisher() *Publisher
 }
 
+// Run runs rpc and http servers until termination signal
+// any startup or server error terminates process with non zero exit code
 func Run(name string, cfg Configer, setup func(srv server.Server, s Servicer) error) {
 	l := newLoggerWrapper(name)
 
+	if err := run(name, cfg, setup, l); err != nil {
+		l.WithError(err).Error("service failed")
+		os.Exit(1)
+	}
+}
+
+func run(name string, cfg Configer, setup func(srv server.Server, s Servicer) error, l *loggerWrapper) error {
 	if name == "" {
-		l.Error("service name is empty")
-		return
+		return errors.New("service name is empty")
 	}
 
-	if err := loadConfig(cfg, os.Getenv("FS_CONFIG_FILE")); err != nil {
-		l.WithError(err).Error("load config error")
-		return
+	loader, err := newConfigLoader(cfg)
+	if err != nil {
+		return fmt.Errorf("config loader: %w", err)
 	}
 
-	serviceCfg := cfg.Service()
+	// signals are handled here to stop http server before rpc one
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
+	// every service gets own command line, server and client, so several services are able to run in single process
 	srv := micro.NewService(
+		micro.Cmd(cmd.NewCmd()),
+		micro.Server(server.NewServer()),
+		micro.Client(client.NewClient(client.Selector(selector.NewSelector()))),
 		micro.Name(name),
 		micro.WrapHandler(makeLoggerWrapper(l)),
+		micro.Context(ctx),
+		micro.HandleSignal(false),
+		micro.Flags(loader.cliFlags()...),
+		micro.Action(loader.parseFlags),
 	)
 
 	srv.Init()
 
-	cm, err := newClientMananger(srv, serviceCfg.AuthServiceName, serviceCfg.FileServiceName)
+	if err := loader.load(); err != nil {
+		return fmt.Errorf("load config error: %w", err)
+	}
+
+	serviceCfg := loader.service()
+
+	if err := l.s.configure(serviceCfg.Log); err != nil {
+		return fmt.Errorf("configure logger: %w", err)
+	}
+	defer l.s.close()
+
+	discovery, err := discoveryOptions(serviceCfg)
 	if err != nil {
-		l.WithError(err).Error("create client manager")
-		return
+		return err
 	}
+	srv.Init(discovery...)
+
+	if serviceCfg.RPCTLS.Enabled() {
+		tlsCfg, err := makeTLSConfig(ctx, serviceCfg.RPCTLS, true, l)
+		if err != nil {
+			return fmt.Errorf("rpc tls: %w", err)
+		}
+
+		srv.Init(micro.Transport(transport.NewHTTPTransport(transport.TLSConfig(tlsCfg))))
+	}
+
+	cm, err := newClientMananger(srv, serviceCfg, l)
+	if err != nil {
+		return fmt.Errorf("create client manager: %w", err)
+	}
+	defer cm.close()
 
 	srvOptions := service{
 		l:         l,
@@ -56,43 +108,136 @@ func Run(name string, cfg Configer, setup func(srv server.Server, s Servicer) er
 		publisher: newPublisher(srv.Client()),
 	}
 
+	// log configuration is applied first, so other reload actions log with new settings
+	srvOptions.AddOption(WithReloadAction(func() {
+		if err := l.s.configure(loader.service().Log); err != nil {
+			l.WithError(err).Error("unable to apply log configuration")
+		}
+	}))
+
+	srvOptions.router.Use(requestIDMiddleware)
+
+	h := newHealth(srv, serviceCfg)
 	srvOptions.router.Path("/metrics/").Handler(promhttp.Handler())
+	srvOptions.router.Path("/healthz").HandlerFunc(h.liveness)
+	srvOptions.router.Path("/readyz").HandlerFunc(h.readiness)
+	if serviceCfg.Log.LevelEndpoint {
+		levelToken := func() string { return loader.service().Log.LevelToken }
+		srvOptions.router.Path("/log/level").Methods(http.MethodGet, http.MethodPut, http.MethodDelete).Handler(levelAuth(levelToken, http.HandlerFunc(l.s.logLevelHandler)))
+	}
 
 	if err := setup(srv.Server(), &srvOptions); err != nil {
-		l.WithError(err).Error("failed to setup service")
-		return
+		return fmt.Errorf("failed to setup service: %w", err)
 	}
 
 	defer srvOptions.runPostActions()
 
+	// cors is applied first, so rejected requests have cors headers and browser shows error to client
+	var handler http.Handler = srvOptions.router
+	if c := newCSRF(serviceCfg.CSRF, serviceCfg.CORS); c != nil {
+		handler = c.middleware(handler)
+	}
+	if c := newCORS(serviceCfg.CORS); c != nil {
+		handler = c.middleware(handler)
+	}
+
 	httpServer := http.Server{
-		Addr:    fmt.Sprintf(":%d", serviceCfg.Port),
-		Handler: srvOptions.router,
+		Handler: handler,
+	}
+
+	if serviceCfg.TLS.Enabled() {
+		httpServer.TLSConfig, err = makeTLSConfig(ctx, serviceCfg.TLS, false, l)
+		if err != nil {
+			return fmt.Errorf("http tls: %w", err)
+		}
 	}
 
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", serviceCfg.Port))
+	if err != nil {
+		return fmt.Errorf("http listen: %w", err)
+	}
+
+	httpErr := make(chan error, 1)
 	go func() {
 		l.Infof("http server started at %d", serviceCfg.Port)
-		defer l.Info("http server stopped")
-		if err := httpServer.ListenAndServe(); err != nil {
-			l.WithError(err).Error("failed to run http server")
-			return
+		var err error
+		if httpServer.TLSConfig != nil {
+			err = httpServer.ServeTLS(listener, "", "")
+		} else {
+			err = httpServer.Serve(listener)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
 		}
+		httpErr <- err
 	}()
 
-	l.Info("rpc server started")
-	if err := srv.Run(); err != nil {
-		l.WithError(err).Error("failed to run service")
-		return
+	go watchConfig(ctx, loader, &srvOptions, l)
+
+	rpcErr := make(chan error, 1)
+	go func() {
+		l.Info("rpc server started")
+		rpcErr <- srv.Run()
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	defer signal.Stop(sig)
+
+	var runErr error
+	rpcStopped := false
+	select {
+	case s := <-sig:
+		l.Infof("received %s signal, shutting down", s)
+	case err := <-httpErr:
+		runErr = fmt.Errorf("http server: %w", err)
+	case err := <-rpcErr:
+		rpcStopped = true
+		runErr = fmt.Errorf("rpc server stopped unexpectedly: %v", err)
+	}
+
+	h.setDraining()
+
+	if d := loader.service().DrainDelay; d > 0 && runErr == nil {
+		l.Infof("draining for %d seconds before shutdown", d)
+		time.Sleep(time.Duration(d) * time.Second)
+	}
+
+	if err := shutdownHTTP(&httpServer, loader.service().ShutdownTimeout, l); err != nil && runErr == nil {
+		runErr = err
+	}
+
+	cancel()
+	if !rpcStopped {
+		if err := <-rpcErr; err != nil && runErr == nil {
+			runErr = fmt.Errorf("rpc server: %w", err)
+		}
 	}
 	l.Info("rpc server stopped")
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	return runErr
+}
+
+// shutdownHTTP stops accepting connections and waits for in-flight requests within grace period
+// connections still active after grace period are closed
+func shutdownHTTP(s *http.Server, gracePeriod int, l Logger) error {
+	timeout := defaultShutdownTimeout
+	if gracePeriod > 0 {
+		timeout = time.Duration(gracePeriod) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
-		l.WithError(err).Error("failed to shutdown http server")
-		return
+	if err := s.Shutdown(ctx); err != nil {
+		l.WithError(err).Warn("grace period expired, closing active http connections")
+		if err := s.Close(); err != nil {
+			return fmt.Errorf("close http server: %w", err)
+		}
 	}
+
+	l.Info("http server stopped")
+	return nil
 }
 
 func makeLoggerWrapper(l Logger) server.HandlerWrapper {
@@ -100,7 +245,9 @@ func makeLoggerWrapper(l Logger) server.HandlerWrapper {
 		return func(ctx context.Context, req server.Request, rsp interface{}) error {
 			l.Infof("processing %s", req.Method())
 			start := time.Now()
-			defer l.Infof("end processing %s, time = %v", req.Method(), time.Since(start))
+			defer func() {
+				l.Infof("end processing %s, time = %v", req.Method(), time.Since(start))
+			}()
 			err := fn(ctx, req, rsp)
 			if err != nil {
 				l.WithError(err).WithFields(map[string]interface{}{
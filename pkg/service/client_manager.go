@@ -1,38 +1,99 @@
 package service
 
 import (
+	"crypto/rsa"
 	"fmt"
+	"time"
 
 	"github.com/asim/go-micro/v3"
 
+	"github.com/Mikhalevich/filesharing/pkg/jwks"
 	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
 	"github.com/Mikhalevich/filesharing/pkg/proto/file"
+	"github.com/Mikhalevich/filesharing/pkg/proto/history"
 	"github.com/Mikhalevich/filesharing/pkg/service/internal/client"
 )
 
 type ClientManager struct {
-	auth *client.GRPCAuthServiceClient
-	file *client.GRPCFileServiceClient
+	auth    *client.GRPCAuthServiceClient
+	file    *client.GRPCFileServiceClient
+	history *client.GRPCHistoryServiceClient
+	keys    *jwks.Set
 }
 
-func newClientMananger(srv micro.Service, authName, fileName string) (*ClientManager, error) {
+func newClientMananger(srv micro.Service, cfg Config, l Logger) (*ClientManager, error) {
 	c := ClientManager{}
 
-	if authName != "" {
-		grpcAuth, err := client.NewGRPCAuthServiceClient(auth.NewAuthService(authName, srv.Client()))
+	resilience := client.NewResilience(cfg.RPC)
+	rpc := resilience.Wrap(srv.Client())
+
+	if cfg.AuthServiceName != "" {
+		authService := auth.NewAuthService(cfg.AuthServiceName, rpc)
+
+		keys, err := newKeySet(authService, cfg.JWT, l)
+		if err != nil {
+			return nil, fmt.Errorf("jwt keys error: %w", err)
+		}
+		c.keys = keys
+
+		grpcAuth, err := client.NewGRPCAuthServiceClient(authService, keys)
 		if err != nil {
 			return nil, fmt.Errorf("auth service error: %w", err)
 		}
 		c.auth = grpcAuth
 	}
 
-	if fileName != "" {
-		c.file = client.NewGRPCFileServiceClient(file.NewFileService(fileName, srv.Client()))
+	if cfg.FileServiceName != "" {
+		c.file = client.NewGRPCFileServiceClient(file.NewFileService(cfg.FileServiceName, rpc), cfg.FileChunkSize, resilience)
+	}
+
+	if cfg.HistoryServiceName != "" {
+		c.history = client.NewGRPCHistoryServiceClient(history.NewHistoryService(cfg.HistoryServiceName, rpc))
 	}
 
 	return &c, nil
 }
 
+func newKeySet(authService auth.AuthService, cfg JWTConfig, l Logger) (*jwks.Set, error) {
+	interval := time.Duration(cfg.ReloadInterval) * time.Second
+	onError := func(err error) {
+		l.WithError(err).Error("reload jwt keys")
+	}
+
+	switch cfg.KeySource {
+	case "file":
+		modTime, err := jwks.PathModTime(cfg.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		changed := func() (bool, error) {
+			t, err := jwks.PathModTime(cfg.KeyPath)
+			if err != nil {
+				return false, err
+			}
+
+			if !t.After(modTime) {
+				return false, nil
+			}
+
+			modTime = t
+			return true, nil
+		}
+
+		return jwks.NewSet(func() (map[string]*rsa.PublicKey, error) {
+			return jwks.LoadPath(cfg.KeyPath)
+		}, changed, interval, onError)
+
+	case "jwks":
+		return jwks.NewLazySet(func() (map[string]*rsa.PublicKey, error) {
+			return client.PublicKeys(authService)
+		}, interval, onError), nil
+	}
+
+	return nil, nil
+}
+
 func (cm *ClientManager) Auth() *client.GRPCAuthServiceClient {
 	return cm.auth
 }
@@ -40,3 +101,13 @@ func (cm *ClientManager) Auth() *client.GRPCAuthServiceClient {
 func (cm *ClientManager) File() *client.GRPCFileServiceClient {
 	return cm.file
 }
+
+func (cm *ClientManager) History() *client.GRPCHistoryServiceClient {
+	return cm.history
+}
+
+func (cm *ClientManager) close() {
+	if cm.keys != nil {
+		cm.keys.Close()
+	}
+}
@@ -2,45 +2,331 @@ package service
 
 import (
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
-	"gopkg.in/yaml.v2"
+	"github.com/Mikhalevich/filesharing/pkg/service/internal/client"
 )
 
 type Configer interface {
 	Service() Config
 	Validate() error
+	// Reloadable returns yaml paths of fields safe to change at runtime
+	// like "rate_limit" or "service.shutdown_timeout"
+	Reloadable() []string
 }
 
+const (
+	defaultShutdownTimeout = time.Minute
+)
+
+// Config common service configuration
+// shutdown_timeout is grace period in seconds for in-flight http requests, zero means one minute
+// drain_delay is time in seconds service keeps serving after readiness turns draining, so balancers stop routing to it
 type Config struct {
-	Port            int    `yaml:"port"`
-	FileServiceName string `yaml:"file_service_name"`
-	AuthServiceName string `yaml:"auth_service_name"`
+	Port               int                     `yaml:"port"`
+	FileServiceName    string                  `yaml:"file_service_name"`
+	AuthServiceName    string                  `yaml:"auth_service_name"`
+	HistoryServiceName string                  `yaml:"history_service_name"`
+	JWT                JWTConfig               `yaml:"jwt"`
+	FileChunkSize      int                     `yaml:"file_chunk_size"`
+	ShutdownTimeout    int                     `yaml:"shutdown_timeout"`
+	DrainDelay         int                     `yaml:"drain_delay"`
+	Log                LogConfig               `yaml:"log"`
+	TLS                TLSConfig               `yaml:"tls"`
+	RPCTLS             TLSConfig               `yaml:"rpc_tls"`
+	Registry           RegistryConfig          `yaml:"registry"`
+	Broker             BrokerConfig            `yaml:"broker"`
+	RPC                client.ResilienceConfig `yaml:"rpc"`
+	CORS               CORSConfig              `yaml:"cors"`
+	CSRF               CSRFConfig              `yaml:"csrf"`
+}
+
+// CORSConfig cross-origin requests from browsers, cors is disabled without allowed_origins
+// "*" allows any origin, it can't be used with credentials; omitted methods and headers are set to defaults
+// max_age is time in seconds preflight response is cached by browser
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age"`
+}
+
+// CSRFConfig protection of state changing requests made by browsers
+// origin of such requests should be the same host, trusted or cors allowed one,
+// requests with cookies should also pass csrf cookie value in header
+type CSRFConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	TrustedOrigins []string `yaml:"trusted_origins"`
+	CookieName     string   `yaml:"cookie_name"`
+	HeaderName     string   `yaml:"header_name"`
+}
+
+// RegistryConfig service discovery, go-micro default one is used if type is omitted
+// type: mdns - multicast dns, memory - shared by services running in single process,
+// static - fixed list of nodes, etcd - etcd cluster
+// addresses are etcd endpoints, nodes are service@host:port items for static registry
+type RegistryConfig struct {
+	Type      string   `yaml:"type"`
+	Addresses []string `yaml:"addresses"`
+	Nodes     []string `yaml:"nodes"`
+}
+
+// BrokerConfig message broker, go-micro default one is used if type is omitted
+// type: http - direct delivery to subscribers found in registry, nats, memory - single process
+// addresses are nats servers
+type BrokerConfig struct {
+	Type      string   `yaml:"type"`
+	Addresses []string `yaml:"addresses"`
+}
+
+// TLSConfig certificate and private key pem files, tls is disabled without cert_file
+// ca_file verifies peer certificates: http clients are required to present one if it's set,
+// for rpc it's required and used in both directions
+// server_name is expected name in rpc server certificates, dialed address is verified if omitted
+// reload_interval in seconds to check certificate files for changes, zero disables reload
+type TLSConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	CAFile         string `yaml:"ca_file"`
+	ServerName     string `yaml:"server_name"`
+	ReloadInterval int    `yaml:"reload_interval"`
+}
+
+// JWTConfig describes where token verification keys are loaded from
+// key_source: embedded - key compiled into auth service package
+// file - pem file or directory with pem files named by kid
+// jwks - json web key set fetched from auth service
+type JWTConfig struct {
+	KeySource      string `yaml:"key_source"`
+	KeyPath        string `yaml:"key_path"`
+	ReloadInterval int    `yaml:"reload_interval"`
+}
+
+// LogConfig logger configuration
+// level: debug, info, warn or error, info by default
+// format: ecs - elastic common schema json, logfmt or console - human readable colored text
+// output: stdout, stderr or file path, stderr by default
+// packages overrides level for package import path or its suffix like internal/handler
+// level_endpoint enables /log/level route to change level at runtime, level_token is required as bearer token
+type LogConfig struct {
+	Level         string            `yaml:"level"`
+	Format        string            `yaml:"format"`
+	Output        string            `yaml:"output"`
+	Packages      map[string]string `yaml:"packages"`
+	Sampling      SamplingConfig    `yaml:"sampling"`
+	LevelEndpoint bool              `yaml:"level_endpoint"`
+	LevelToken    string            `yaml:"level_token"`
+}
+
+// SamplingConfig limits repeated messages below error level
+// first initial messages with the same text are logged every period in seconds, then every thereafter one
+// zero initial disables sampling
+type SamplingConfig struct {
+	Initial    int `yaml:"initial"`
+	Thereafter int `yaml:"thereafter"`
+	Period     int `yaml:"period"`
 }
 
 func (c Config) Validate() error {
 	if c.Port <= 0 {
 		return fmt.Errorf("invalid port: %d", c.Port)
 	}
+
+	if err := c.JWT.Validate(); err != nil {
+		return fmt.Errorf("jwt: %w", err)
+	}
+
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout: %d", c.ShutdownTimeout)
+	}
+
+	if c.DrainDelay < 0 {
+		return fmt.Errorf("invalid drain delay: %d", c.DrainDelay)
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+
+	if err := c.RPCTLS.Validate(); err != nil {
+		return fmt.Errorf("rpc_tls: %w", err)
+	}
+
+	if c.RPCTLS.Enabled() && c.RPCTLS.CAFile == "" {
+		return fmt.Errorf("rpc_tls: ca_file is required")
+	}
+
+	if err := c.Registry.Validate(); err != nil {
+		return fmt.Errorf("registry: %w", err)
+	}
+
+	if err := c.Broker.Validate(); err != nil {
+		return fmt.Errorf("broker: %w", err)
+	}
+
+	// http broker finds subscribers in registry, static one knows nothing about them
+	if c.Registry.Type == "static" && (c.Broker.Type == "" || c.Broker.Type == "http") {
+		return fmt.Errorf("static registry requires nats or memory broker")
+	}
+
+	if err := c.RPC.Validate(); err != nil {
+		return fmt.Errorf("rpc: %w", err)
+	}
+
+	if err := c.Log.Validate(); err != nil {
+		return fmt.Errorf("log: %w", err)
+	}
+
+	if err := c.CORS.Validate(); err != nil {
+		return fmt.Errorf("cors: %w", err)
+	}
+
+	if err := c.CSRF.Validate(); err != nil {
+		return fmt.Errorf("csrf: %w", err)
+	}
+
+	// grpc limits message size to 4MB by default
+	if c.FileChunkSize < 0 || c.FileChunkSize > 2<<20 {
+		return fmt.Errorf("invalid file chunk size: %d", c.FileChunkSize)
+	}
+
+	return nil
+}
+
+func (c JWTConfig) Validate() error {
+	switch c.KeySource {
+	case "", "embedded", "jwks":
+	case "file":
+		if c.KeyPath == "" {
+			return fmt.Errorf("key_path is required for file key source")
+		}
+	default:
+		return fmt.Errorf("unknown key source: %q", c.KeySource)
+	}
+
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("invalid reload interval: %d", c.ReloadInterval)
+	}
+
 	return nil
 }
 
-func loadConfig(cfg Configer, path string) error {
-	buf, err := os.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("read file error: %w", err)
+func (c LogConfig) Validate() error {
+	if _, err := parseLevel(c.Level); err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "", "ecs", "logfmt", "console":
+	default:
+		return fmt.Errorf("unknown format: %q", c.Format)
 	}
 
-	if err := yaml.Unmarshal(buf, cfg); err != nil {
-		return fmt.Errorf("unmarshal yml error: %w", err)
+	for pkg, level := range c.Packages {
+		if pkg == "" {
+			return fmt.Errorf("empty package name")
+		}
+
+		if _, err := parseLevel(level); err != nil {
+			return fmt.Errorf("package %s: %w", pkg, err)
+		}
 	}
 
-	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("validate error: %w", err)
+	if c.Sampling.Initial < 0 || c.Sampling.Thereafter < 0 || c.Sampling.Period < 0 {
+		return fmt.Errorf("invalid sampling initial = %d thereafter = %d period = %d", c.Sampling.Initial, c.Sampling.Thereafter, c.Sampling.Period)
 	}
 
-	if err := cfg.Service().Validate(); err != nil {
-		return fmt.Errorf("service validate error: %w", err)
+	if c.LevelEndpoint && c.LevelToken == "" {
+		return fmt.Errorf("level_token is required for level endpoint")
+	}
+
+	return nil
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+func (c TLSConfig) Validate() error {
+	if !c.Enabled() {
+		if c.KeyFile != "" || c.CAFile != "" {
+			return fmt.Errorf("cert_file is required")
+		}
+		return nil
+	}
+
+	if c.KeyFile == "" {
+		return fmt.Errorf("key_file is required")
+	}
+
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("invalid reload interval: %d", c.ReloadInterval)
+	}
+
+	return nil
+}
+
+func (c RegistryConfig) Validate() error {
+	switch c.Type {
+	case "", "mdns", "memory":
+	case "static":
+		if _, err := parseStaticNodes(c.Nodes); err != nil {
+			return err
+		}
+	case "etcd":
+		if len(c.Addresses) == 0 {
+			return fmt.Errorf("addresses are required for etcd registry")
+		}
+	default:
+		return fmt.Errorf("unknown type: %q", c.Type)
+	}
+
+	return nil
+}
+
+func (c BrokerConfig) Validate() error {
+	switch c.Type {
+	case "", "http", "memory":
+	case "nats":
+		if len(c.Addresses) == 0 {
+			return fmt.Errorf("addresses are required for nats broker")
+		}
+	default:
+		return fmt.Errorf("unknown type: %q", c.Type)
+	}
+
+	return nil
+}
+
+func (c CORSConfig) Validate() error {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("any origin can't be allowed with credentials")
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(o, "http://") && !strings.HasPrefix(o, "https://") {
+			return fmt.Errorf("invalid origin: %q", o)
+		}
+	}
+
+	if c.MaxAge < 0 {
+		return fmt.Errorf("invalid max age: %d", c.MaxAge)
+	}
+
+	return nil
+}
+
+func (c CSRFConfig) Validate() error {
+	for _, o := range c.TrustedOrigins {
+		if !strings.HasPrefix(o, "http://") && !strings.HasPrefix(o, "https://") {
+			return fmt.Errorf("invalid origin: %q", o)
+		}
 	}
 
 	return nil
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	configFileFlag = "config_file"
+)
+
+// configField leaf configuration field addressed by yaml path
+type configField struct {
+	path  []string
+	value reflect.Value
+}
+
+// envName environment variable name like FS_SERVICE_PORT
+func (f configField) envName() string {
+	return "FS_" + strings.ToUpper(strings.Join(f.path, "_"))
+}
+
+// flagName command line flag name like service.port
+func (f configField) flagName() string {
+	return strings.Join(f.path, ".")
+}
+
+func yamlName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("yaml")
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			return "", true
+		}
+	}
+
+	if parts[0] != "" {
+		return parts[0], false
+	}
+
+	return strings.ToLower(sf.Name), false
+}
+
+// configFields returns leaf fields of configuration struct
+func configFields(v reflect.Value, path []string) []configField {
+	var fields []configField
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if sf.PkgPath != "" || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+
+		name, inline := yamlName(sf)
+		fieldPath := path
+		if !inline {
+			fieldPath = append(append([]string(nil), path...), name)
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, configFields(v.Field(i), fieldPath)...)
+			continue
+		}
+
+		fields = append(fields, configField{
+			path:  fieldPath,
+			value: v.Field(i),
+		})
+	}
+	return fields
+}
+
+// findField returns field by yaml path, path may point to nested struct
+func findField(v reflect.Value, path []string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name, inline := yamlName(sf)
+		if inline {
+			if f, ok := findField(v.Field(i), path); ok {
+				return f, true
+			}
+			continue
+		}
+
+		if name != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			return v.Field(i), true
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			return findField(v.Field(i), path[1:])
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// setField parses string value into field
+// slices are comma separated, maps are comma separated key=value pairs
+func setField(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		items := splitList(s)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setField(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type: %s", v.Type().Key())
+		}
+
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitList(s) {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid map item: %s", item)
+			}
+
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := setField(val, strings.TrimSpace(kv[1])); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(kv[0])), val)
+		}
+		v.Set(m)
+
+	default:
+		return fmt.Errorf("unsupported type: %s", v.Type())
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// configLoader fills configuration from layers, each next one overrides previous:
+// defaults set before Run, yaml file, environment variables, secret files and command line flags
+// secret file is set by environment variable with _FILE suffix like FS_MAIL_SMTP_PASSWORD_FILE
+type configLoader struct {
+	mu       sync.RWMutex
+	cfg      Configer
+	defaults []byte
+	path     string
+	flags    map[string]string
+}
+
+func newConfigLoader(cfg Configer) (*configLoader, error) {
+	defaults, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal defaults: %w", err)
+	}
+
+	return &configLoader{
+		cfg:      cfg,
+		defaults: defaults,
+		path:     os.Getenv("FS_CONFIG_FILE"),
+		flags:    make(map[string]string),
+	}, nil
+}
+
+// cliFlags returns flag for every configuration field
+func (l *configLoader) cliFlags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:  configFileFlag,
+			Usage: "yaml configuration file, overrides FS_CONFIG_FILE",
+		},
+	}
+
+	for _, f := range configFields(reflect.ValueOf(l.cfg).Elem(), nil) {
+		flags = append(flags, &cli.StringFlag{
+			Name:  f.flagName(),
+			Usage: fmt.Sprintf("overrides %s", f.envName()),
+		})
+	}
+
+	return flags
+}
+
+// parseFlags cli action which stores flags set by user
+func (l *configLoader) parseFlags(c *cli.Context) error {
+	if c.IsSet(configFileFlag) {
+		l.path = c.String(configFileFlag)
+	}
+
+	for _, f := range configFields(reflect.ValueOf(l.cfg).Elem(), nil) {
+		if c.IsSet(f.flagName()) {
+			l.flags[f.flagName()] = c.String(f.flagName())
+		}
+	}
+
+	return nil
+}
+
+func (l *configLoader) fill(cfg Configer) error {
+	if l.path != "" {
+		buf, err := os.ReadFile(l.path)
+		if err != nil {
+			return fmt.Errorf("read file error: %w", err)
+		}
+
+		if err := yaml.Unmarshal(buf, cfg); err != nil {
+			return fmt.Errorf("unmarshal yml error: %w", err)
+		}
+	}
+
+	fields := configFields(reflect.ValueOf(cfg).Elem(), nil)
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.envName()); ok {
+			if err := setField(f.value, v); err != nil {
+				return fmt.Errorf("env %s: %w", f.envName(), err)
+			}
+		}
+
+		if p, ok := os.LookupEnv(f.envName() + "_FILE"); ok && f.value.Kind() == reflect.String {
+			secret, err := os.ReadFile(p)
+			if err != nil {
+				return fmt.Errorf("secret %s: %w", f.envName(), err)
+			}
+			f.value.SetString(strings.TrimRight(string(secret), "\r\n"))
+		}
+	}
+
+	for _, f := range fields {
+		if v, ok := l.flags[f.flagName()]; ok {
+			if err := setField(f.value, v); err != nil {
+				return fmt.Errorf("flag %s: %w", f.flagName(), err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("validate error: %w", err)
+	}
+
+	if err := cfg.Service().Validate(); err != nil {
+		return fmt.Errorf("service validate error: %w", err)
+	}
+
+	return nil
+}
+
+func (l *configLoader) load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fill(l.cfg)
+}
+
+// reload loads configuration again and applies reloadable fields only
+// invalid configuration is rejected as a whole
+func (l *configLoader) reload() error {
+	fresh, ok := reflect.New(reflect.TypeOf(l.cfg).Elem()).Interface().(Configer)
+	if !ok {
+		return fmt.Errorf("unable to create config of type %T", l.cfg)
+	}
+
+	if err := yaml.Unmarshal(l.defaults, fresh); err != nil {
+		return fmt.Errorf("unmarshal defaults: %w", err)
+	}
+
+	if err := l.fill(fresh); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := reflect.ValueOf(l.cfg).Elem()
+	updated := reflect.ValueOf(fresh).Elem()
+
+	type change struct {
+		dst reflect.Value
+		src reflect.Value
+	}
+	changes := make([]change, 0, len(l.cfg.Reloadable()))
+	for _, p := range l.cfg.Reloadable() {
+		path := strings.Split(p, ".")
+		dst, ok := findField(current, path)
+		if !ok {
+			return fmt.Errorf("unknown reloadable field: %s", p)
+		}
+
+		src, _ := findField(updated, path)
+		changes = append(changes, change{dst: dst, src: src})
+	}
+
+	for _, c := range changes {
+		c.dst.Set(c.src)
+	}
+
+	return nil
+}
+
+// service returns current service configuration
+func (l *configLoader) service() Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cfg.Service()
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	configReloadDelay = time.Second
+)
+
+// watchConfig reloads configuration on SIGHUP or config file change
+// directory is watched because editors and kubernetes replace file instead of writing it
+func watchConfig(ctx context.Context, loader *configLoader, s *service, l Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var (
+		events chan fsnotify.Event
+		errs   chan error
+	)
+	if loader.path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			l.WithError(err).Error("unable to create config watcher")
+		} else {
+			defer watcher.Close()
+
+			if err := watcher.Add(filepath.Dir(loader.path)); err != nil {
+				l.WithError(err).Error("unable to watch config directory")
+			}
+			events = watcher.Events
+			errs = watcher.Errors
+		}
+	}
+
+	// several events are fired on single file update
+	timer := time.NewTimer(configReloadDelay)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			timer.Reset(0)
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+
+			if filepath.Base(e.Name) == filepath.Base(loader.path) || filepath.Base(e.Name) == "..data" {
+				timer.Reset(configReloadDelay)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			l.WithError(err).Error("config watcher error")
+		case <-timer.C:
+			if err := loader.reload(); err != nil {
+				l.WithError(err).Error("unable to reload config, keeping current one")
+				continue
+			}
+
+			l.Info("config reloaded")
+			s.runReloadActions()
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "X-Request-ID", "X-CSRF-Token"}
+	defaultCORSExposed = []string{"X-Token", "X-Request-ID", "Retry-After", "Content-Disposition"}
+)
+
+type cors struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	methods     map[string]bool
+	allowMethod string
+	allowHeader string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+func newCORS(c CORSConfig) *cors {
+	if len(c.AllowedOrigins) == 0 {
+		return nil
+	}
+
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+
+	headers := c.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	exposed := c.ExposedHeaders
+	if len(exposed) == 0 {
+		exposed = defaultCORSExposed
+	}
+
+	cr := cors{
+		origins:     make(map[string]bool, len(c.AllowedOrigins)),
+		methods:     make(map[string]bool, len(methods)),
+		allowMethod: strings.Join(methods, ", "),
+		allowHeader: strings.Join(headers, ", "),
+		expose:      strings.Join(exposed, ", "),
+		credentials: c.AllowCredentials,
+	}
+
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			cr.anyOrigin = true
+			continue
+		}
+		cr.origins[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+	}
+
+	for _, m := range methods {
+		cr.methods[strings.ToUpper(m)] = true
+	}
+
+	if c.MaxAge > 0 {
+		cr.maxAge = strconv.Itoa(c.MaxAge)
+	}
+
+	return &cr
+}
+
+func (c *cors) allowed(origin string) bool {
+	return c.anyOrigin || c.origins[strings.ToLower(origin)]
+}
+
+// middleware wraps whole router, preflight requests don't match any route
+func (c *cors) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !c.allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if c.anyOrigin && !c.credentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if c.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", c.expose)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if c.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))] {
+			h.Set("Access-Control-Allow-Methods", c.allowMethod)
+			h.Set("Access-Control-Allow-Headers", c.allowHeader)
+			if c.maxAge != "" {
+				h.Set("Access-Control-Max-Age", c.maxAge)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+)
+
+const (
+	defaultCSRFCookie = "csrf_token"
+	defaultCSRFHeader = "X-CSRF-Token"
+)
+
+// csrf rejects state changing requests made by browser from foreign site
+// browsers send origin with cross-site requests, so clients without it like curl are not affected
+// double submit token is checked for requests with cookies, foreign site is not able to read cookie value
+type csrf struct {
+	trusted map[string]bool
+	cookie  string
+	header  string
+}
+
+func newCSRF(c CSRFConfig, cc CORSConfig) *csrf {
+	if !c.Enabled {
+		return nil
+	}
+
+	cs := csrf{
+		trusted: make(map[string]bool),
+		cookie:  c.CookieName,
+		header:  c.HeaderName,
+	}
+
+	if cs.cookie == "" {
+		cs.cookie = defaultCSRFCookie
+	}
+
+	if cs.header == "" {
+		cs.header = defaultCSRFHeader
+	}
+
+	// any origin allowed for cors doesn't make it trusted
+	for _, o := range append(c.TrustedOrigins, cc.AllowedOrigins...) {
+		if o != "*" {
+			cs.trusted[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+		}
+	}
+
+	return &cs
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// sameOrigin checks origin header or referer if origin is not sent
+func (c *csrf) sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		ref := r.Header.Get("Referer")
+		if ref == "" {
+			return true
+		}
+
+		u, err := url.Parse(ref)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	if c.trusted[strings.ToLower(origin)] {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func makeCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (c *csrf) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(c.cookie)
+
+		if isSafeMethod(r.Method) {
+			// cookie is readable by scripts of the same site, which should pass it in header
+			if err != nil {
+				if token, err := makeCSRFToken(); err == nil {
+					http.SetCookie(w, &http.Cookie{
+						Name:     c.cookie,
+						Value:    token,
+						Path:     "/",
+						Secure:   r.TLS != nil,
+						SameSite: http.SameSiteLaxMode,
+					})
+				}
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.sameOrigin(r) {
+			httperror.NewForbidden("cross-site request is not allowed").WriteJSON(w)
+			return
+		}
+
+		if len(r.Cookies()) > 0 {
+			header := r.Header.Get(c.header)
+			if err != nil || header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+				httperror.NewForbidden("invalid csrf token").WriteJSON(w)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	natsBroker "github.com/asim/go-micro/plugins/broker/nats/v3"
+	etcdRegistry "github.com/asim/go-micro/plugins/registry/etcd/v3"
+	"github.com/asim/go-micro/v3"
+	"github.com/asim/go-micro/v3/broker"
+	"github.com/asim/go-micro/v3/registry"
+
+	"github.com/Mikhalevich/filesharing/pkg/service/internal/memory"
+)
+
+// memory registry and broker are shared by all services of the process
+// go-micro initialization touches global defaults, so such services should be started one by one
+var (
+	memoryOnce     sync.Once
+	memoryRegistry registry.Registry
+	memoryBroker   broker.Broker
+)
+
+func sharedMemory() (registry.Registry, broker.Broker) {
+	memoryOnce.Do(func() {
+		memoryRegistry = registry.NewMemoryRegistry()
+		memoryBroker = memory.NewBroker()
+	})
+	return memoryRegistry, memoryBroker
+}
+
+// parseStaticNodes converts service@address items into registry services
+func parseStaticNodes(nodes []string) (map[string][]*registry.Service, error) {
+	services := make(map[string][]*registry.Service)
+	for _, n := range nodes {
+		parts := strings.SplitN(n, "@", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid node %q, expected service@address", n)
+		}
+
+		name, addr := parts[0], parts[1]
+		if len(services[name]) == 0 {
+			services[name] = []*registry.Service{{
+				Name:    name,
+				Version: "latest",
+			}}
+		}
+
+		s := services[name][0]
+		s.Nodes = append(s.Nodes, &registry.Node{
+			Id:      name + "-" + addr,
+			Address: addr,
+		})
+	}
+	return services, nil
+}
+
+func makeRegistry(c RegistryConfig) (registry.Registry, error) {
+	switch c.Type {
+	case "mdns":
+		return registry.NewRegistry(), nil
+	case "memory":
+		r, _ := sharedMemory()
+		return r, nil
+	case "static":
+		services, err := parseStaticNodes(c.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		return registry.NewMemoryRegistry(registry.Services(services)), nil
+	case "etcd":
+		return etcdRegistry.NewRegistry(registry.Addrs(c.Addresses...)), nil
+	}
+	return nil, fmt.Errorf("unknown registry type: %q", c.Type)
+}
+
+func makeBroker(c BrokerConfig) (broker.Broker, error) {
+	switch c.Type {
+	case "http":
+		return broker.NewBroker(), nil
+	case "memory":
+		_, b := sharedMemory()
+		return b, nil
+	case "nats":
+		return natsBroker.NewBroker(broker.Addrs(c.Addresses...)), nil
+	}
+	return nil, fmt.Errorf("unknown broker type: %q", c.Type)
+}
+
+// discoveryOptions returns options replacing go-micro default registry and broker
+// broker is set first because registry option passes registry to broker
+func discoveryOptions(c Config) ([]micro.Option, error) {
+	var opts []micro.Option
+
+	if c.Broker.Type != "" {
+		b, err := makeBroker(c.Broker)
+		if err != nil {
+			return nil, fmt.Errorf("broker: %w", err)
+		}
+		opts = append(opts, micro.Broker(b))
+	}
+
+	if c.Registry.Type != "" {
+		r, err := makeRegistry(c.Registry)
+		if err != nil {
+			return nil, fmt.Errorf("registry: %w", err)
+		}
+		opts = append(opts, micro.Registry(r))
+	}
+
+	return opts, nil
+}
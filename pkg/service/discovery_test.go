@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/asim/go-micro/v3"
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/asim/go-micro/v3/server"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+)
+
+type Echo struct{}
+
+func (e *Echo) Call(ctx context.Context, req *event.StorageEvent, rsp *event.StorageEvent) error {
+	rsp.Name = req.GetName()
+	rsp.Action = req.GetAction()
+	return nil
+}
+
+// newMemoryService builds service the same way application does with memory discovery
+func newMemoryService(t *testing.T, name string) micro.Service {
+	t.Helper()
+
+	opts, err := discoveryOptions(Config{
+		Registry: RegistryConfig{Type: "memory"},
+		Broker:   BrokerConfig{Type: "memory"},
+	})
+	if err != nil {
+		t.Fatalf("discovery options: %v", err)
+	}
+
+	// service Init parses command line, so options are passed to constructor
+	return micro.NewService(append([]micro.Option{
+		micro.Server(server.NewServer()),
+		micro.Client(client.NewClient(client.Selector(selector.NewSelector()))),
+		micro.Name(name),
+		micro.Address("127.0.0.1:0"),
+		micro.HandleSignal(false),
+	}, opts...)...)
+}
+
+func TestMemoryDiscovery(t *testing.T) {
+	files := newMemoryService(t, "test.files")
+	if err := micro.RegisterHandler(files.Server(), new(Echo)); err != nil {
+		t.Fatalf("register handler: %v", err)
+	}
+
+	var received []*event.StorageEvent
+	err := micro.RegisterSubscriber("test.storage", files.Server(), func(ctx context.Context, e *event.StorageEvent) error {
+		received = append(received, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("register subscriber: %v", err)
+	}
+
+	if err := files.Server().Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	web := newMemoryService(t, "test.web")
+
+	var rsp event.StorageEvent
+	req := web.Client().NewRequest("test.files", "Echo.Call", &event.StorageEvent{Name: "storage"})
+	if err := web.Client().Call(context.Background(), req, &rsp); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+
+	if rsp.GetName() != "storage" {
+		t.Fatalf("unexpected response: %v", &rsp)
+	}
+
+	// memory broker delivers message before publish returns
+	pub := micro.NewEvent("test.storage", web.Client())
+	if err := pub.Publish(context.Background(), &event.StorageEvent{Name: "storage", Action: event.StorageAction_StorageDeleted}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if len(received) != 1 || received[0].GetName() != "storage" || received[0].GetAction() != event.StorageAction_StorageDeleted {
+		t.Fatalf("unexpected events: %v", received)
+	}
+
+	if err := files.Server().Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	r, _ := sharedMemory()
+	if _, err := r.GetService("test.files"); !errors.Is(err, registry.ErrNotFound) {
+		t.Fatalf("service is not deregistered: %v", err)
+	}
+
+	if err := pub.Publish(context.Background(), &event.StorageEvent{Name: "storage"}); err != nil {
+		t.Fatalf("publish without subscribers: %v", err)
+	}
+
+	if len(received) != 1 {
+		t.Fatal("stopped service received event")
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/asim/go-micro/v3"
+	"github.com/asim/go-micro/v3/client"
+	debug "github.com/asim/go-micro/v3/debug/proto"
+)
+
+const (
+	healthCheckTimeout = 3 * time.Second
+)
+
+// health serves liveness and readiness probes
+// service is not ready while draining on shutdown
+type health struct {
+	srv          micro.Service
+	dependencies []string
+	draining     int32
+}
+
+func newHealth(srv micro.Service, cfg Config) *health {
+	var deps []string
+	for _, name := range []string{cfg.AuthServiceName, cfg.FileServiceName, cfg.HistoryServiceName} {
+		if name != "" {
+			deps = append(deps, name)
+		}
+	}
+
+	return &health{
+		srv:          srv,
+		dependencies: deps,
+	}
+}
+
+func (h *health) setDraining() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+func (h *health) isDraining() bool {
+	return atomic.LoadInt32(&h.draining) == 1
+}
+
+func (h *health) checkRegistry() error {
+	services, err := h.srv.Options().Registry.GetService(h.srv.Name())
+	if err != nil {
+		return err
+	}
+
+	if len(services) == 0 {
+		return fmt.Errorf("service %s is not registered", h.srv.Name())
+	}
+
+	return nil
+}
+
+// connectionChecker broker reporting its connection state
+type connectionChecker interface {
+	IsConnected() bool
+}
+
+// checkBroker checks broker connection without publishing messages
+// network brokers are checked by dialing broker address, in-process ones by connection state
+func (h *health) checkBroker() error {
+	b := h.srv.Options().Broker
+	if c, ok := b.(connectionChecker); ok {
+		if !c.IsConnected() {
+			return errors.New("broker is not connected")
+		}
+		return nil
+	}
+
+	addr := b.Address()
+	if u, err := url.Parse(addr); err == nil && u.Host != "" {
+		addr = u.Host
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("invalid broker address %q: %w", b.Address(), err)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, healthCheckTimeout)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func (h *health) checkService(ctx context.Context, name string) error {
+	rsp, err := debug.NewDebugService(name, h.srv.Client()).Health(ctx, &debug.HealthRequest{}, client.WithRequestTimeout(healthCheckTimeout))
+	if err != nil {
+		return err
+	}
+
+	if rsp.GetStatus() != "ok" {
+		return fmt.Errorf("status: %s", rsp.GetStatus())
+	}
+
+	return nil
+}
+
+// check runs all readiness checks, failed check contains error description
+func (h *health) check(ctx context.Context) (map[string]string, bool) {
+	results := make(map[string]string, len(h.dependencies)+2)
+	ok := true
+
+	set := func(name string, err error) {
+		if err != nil {
+			results[name] = err.Error()
+			ok = false
+			return
+		}
+		results[name] = "ok"
+	}
+
+	set("registry", h.checkRegistry())
+	set("broker", h.checkBroker())
+
+	for _, name := range h.dependencies {
+		set(name, h.checkService(ctx, name))
+	}
+
+	return results, ok
+}
+
+func writeHealth(w http.ResponseWriter, status string, checks map[string]string) {
+	code := http.StatusOK
+	if status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}{
+		Status: status,
+		Checks: checks,
+	})
+}
+
+// liveness process is alive while it's able to serve http
+func (h *health) liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, "ok", nil)
+}
+
+// readiness checks registry, broker and dependent services
+func (h *health) readiness(w http.ResponseWriter, r *http.Request) {
+	if h.isDraining() {
+		writeHealth(w, "draining", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks, ok := h.check(ctx)
+	if !ok {
+		writeHealth(w, "unavailable", checks)
+		return
+	}
+
+	writeHealth(w, "ok", checks)
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+)
+
+const (
+	breakerErrorID = "go.micro.client.breaker"
+
+	defaultBreakerFailures    = 5
+	defaultBreakerOpenTimeout = 30 * time.Second
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateHalfOpen
+	stateOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateHalfOpen:
+		return "half_open"
+	case stateOpen:
+		return "open"
+	}
+	return "closed"
+}
+
+// BreakerConfig circuit breaker opens after failures consecutive transport errors
+// and lets single probe request through after open_timeout in seconds
+// zero values mean defaults, negative failures disables breaker
+type BreakerConfig struct {
+	Failures    int `yaml:"failures"`
+	OpenTimeout int `yaml:"open_timeout"`
+}
+
+func (c BreakerConfig) Validate() error {
+	if c.OpenTimeout < 0 {
+		return fmt.Errorf("invalid open timeout: %d", c.OpenTimeout)
+	}
+	return nil
+}
+
+// breaker is circuit breaker of single service
+type breaker struct {
+	service     string
+	failures    int
+	openTimeout time.Duration
+
+	mu          sync.Mutex
+	state       breakerState
+	consecutive int
+	openedAt    time.Time
+	probing     bool
+}
+
+func (b *breaker) setState(s breakerState) {
+	b.state = s
+	breakerStateGauge.WithLabelValues(b.service).Set(float64(s))
+}
+
+// allow returns error while breaker is open, half open breaker allows single probe
+func (b *breaker) allow() error {
+	if b.failures < 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			breakerRejectedTotal.WithLabelValues(b.service).Inc()
+			return errors.New(breakerErrorID, fmt.Sprintf("circuit breaker is open for %s", b.service), 503)
+		}
+		b.setState(stateHalfOpen)
+		b.probing = true
+		return nil
+
+	case stateHalfOpen:
+		if b.probing {
+			breakerRejectedTotal.WithLabelValues(b.service).Inc()
+			return errors.New(breakerErrorID, fmt.Sprintf("circuit breaker is half open for %s", b.service), 503)
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+// record updates breaker with call result, only transport errors are counted as failures
+func (b *breaker) record(err error) {
+	if b.failures < 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isRetryable(err) {
+		b.consecutive = 0
+		b.probing = false
+		if b.state != stateClosed {
+			b.setState(stateClosed)
+		}
+		return
+	}
+
+	b.consecutive++
+	b.probing = false
+	if b.state == stateHalfOpen || b.consecutive >= b.failures {
+		b.openedAt = time.Now()
+		b.setState(stateOpen)
+	}
+}
+
+type breakers struct {
+	failures    int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	services map[string]*breaker
+}
+
+func newBreakers(c BreakerConfig) *breakers {
+	failures := c.Failures
+	if failures == 0 {
+		failures = defaultBreakerFailures
+	}
+
+	openTimeout := defaultBreakerOpenTimeout
+	if c.OpenTimeout > 0 {
+		openTimeout = time.Duration(c.OpenTimeout) * time.Second
+	}
+
+	return &breakers{
+		failures:    failures,
+		openTimeout: openTimeout,
+		services:    make(map[string]*breaker),
+	}
+}
+
+func (bs *breakers) get(service string) *breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	b, ok := bs.services[service]
+	if !ok {
+		b = &breaker{
+			service:     service,
+			failures:    bs.failures,
+			openTimeout: bs.openTimeout,
+		}
+		b.setState(stateClosed)
+		bs.services[service] = b
+	}
+	return b
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Mikhalevich/filesharing-auth-service/pkg/token"
+	"github.com/golang-jwt/jwt"
+
+	"github.com/Mikhalevich/filesharing/pkg/jwks"
+)
+
+// Claims token claims issued by auth service
+type Claims struct {
+	User        token.User `json:"user"`
+	MFA         bool       `json:"mfa"`
+	MFAEnrolled bool       `json:"mfa_enrolled"`
+	jwt.StandardClaims
+}
+
+type decoder interface {
+	Decode(tokenString string) (*Claims, error)
+}
+
+// embeddedDecoder verifies tokens with the key compiled into auth service package
+type embeddedDecoder struct {
+	dec token.Decoder
+}
+
+func newEmbeddedDecoder() (*embeddedDecoder, error) {
+	dec, err := token.NewRSADecoder()
+	if err != nil {
+		return nil, fmt.Errorf("unable to crate rsa decoder: %w", err)
+	}
+
+	return &embeddedDecoder{
+		dec: dec,
+	}, nil
+}
+
+func (d *embeddedDecoder) Decode(tokenString string) (*Claims, error) {
+	claims, err := d.dec.Decode(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := mfaClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Claims{
+		User:           claims.User,
+		MFA:            mfa.MFA,
+		MFAEnrolled:    mfa.MFAEnrolled,
+		StandardClaims: claims.StandardClaims,
+	}, nil
+}
+
+// keySetDecoder verifies tokens with key selected by kid header
+type keySetDecoder struct {
+	keys *jwks.Set
+}
+
+func (d *keySetDecoder) Decode(tokenString string) (*Claims, error) {
+	t, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		return d.keys.Key(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := t.Claims.(*Claims)
+	if !ok {
+		return nil, errors.New("invalid token: unable to parse custom claims")
+	}
+
+	return claims, nil
+}
+
+type mfa struct {
+	MFA         bool `json:"mfa"`
+	MFAEnrolled bool `json:"mfa_enrolled"`
+}
+
+// mfaClaims extracts mfa claims from token payload
+// token signature should be verified by decoder before
+func mfaClaims(tokenString string) (mfa, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return mfa{}, errors.New("invalid token: wrong number of segments")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return mfa{}, fmt.Errorf("invalid token payload: %w", err)
+	}
+
+	var claims mfa
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return mfa{}, fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/registry"
+)
+
+const (
+	defaultEjectFailures = 3
+	defaultEjectDuration = 30 * time.Second
+)
+
+// EjectConfig node is excluded from selection for duration in seconds
+// after failures consecutive transport errors
+// zero values mean defaults, negative failures disables ejection
+type EjectConfig struct {
+	Failures int `yaml:"failures"`
+	Duration int `yaml:"duration"`
+}
+
+func (c EjectConfig) Validate() error {
+	if c.Duration < 0 {
+		return fmt.Errorf("invalid duration: %d", c.Duration)
+	}
+	return nil
+}
+
+type nodeState struct {
+	service     string
+	consecutive int
+	ejectedTill time.Time
+}
+
+// nodeHealth tracks failures of service nodes by node id
+type nodeHealth struct {
+	failures int
+	duration time.Duration
+
+	mu    sync.Mutex
+	nodes map[string]*nodeState
+}
+
+func newNodeHealth(c EjectConfig) *nodeHealth {
+	failures := c.Failures
+	if failures == 0 {
+		failures = defaultEjectFailures
+	}
+
+	duration := defaultEjectDuration
+	if c.Duration > 0 {
+		duration = time.Duration(c.Duration) * time.Second
+	}
+
+	return &nodeHealth{
+		failures: failures,
+		duration: duration,
+		nodes:    make(map[string]*nodeState),
+	}
+}
+
+func (h *nodeHealth) record(service string, nodeID string, err error) {
+	if h.failures < 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !isRetryable(err) {
+		if n, ok := h.nodes[nodeID]; ok {
+			delete(h.nodes, nodeID)
+			if !n.ejectedTill.IsZero() {
+				ejectedNodes.WithLabelValues(service).Dec()
+			}
+		}
+		return
+	}
+
+	n, ok := h.nodes[nodeID]
+	if !ok {
+		n = &nodeState{service: service}
+		h.nodes[nodeID] = n
+	}
+
+	n.consecutive++
+	if n.consecutive >= h.failures {
+		if n.ejectedTill.IsZero() {
+			ejectedNodes.WithLabelValues(service).Inc()
+		}
+		n.ejectedTill = time.Now().Add(h.duration)
+	}
+}
+
+// readmit returns nodes to selection once ejection is over, caller holds lock
+// readmitted node is ejected again after single failure
+func (h *nodeHealth) readmit(now time.Time) {
+	for _, n := range h.nodes {
+		if n.ejectedTill.IsZero() || now.Before(n.ejectedTill) {
+			continue
+		}
+
+		n.ejectedTill = time.Time{}
+		n.consecutive = h.failures - 1
+		ejectedNodes.WithLabelValues(n.service).Dec()
+	}
+}
+
+func (h *nodeHealth) ejected(nodeID string, now time.Time) bool {
+	n, ok := h.nodes[nodeID]
+	return ok && now.Before(n.ejectedTill)
+}
+
+// filter removes ejected nodes from selection
+// service is returned as is if all its nodes are ejected, so requests still have a chance
+func (h *nodeHealth) filter(services []*registry.Service) []*registry.Service {
+	if h.failures < 0 {
+		return services
+	}
+
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.nodes) == 0 {
+		return services
+	}
+
+	h.readmit(now)
+
+	filtered := make([]*registry.Service, 0, len(services))
+	for _, s := range services {
+		nodes := make([]*registry.Node, 0, len(s.Nodes))
+		for _, n := range s.Nodes {
+			if !h.ejected(n.Id, now) {
+				nodes = append(nodes, n)
+			}
+		}
+
+		if len(nodes) == len(s.Nodes) {
+			filtered = append(filtered, s)
+			continue
+		}
+
+		if len(nodes) > 0 {
+			copied := *s
+			copied.Nodes = nodes
+			filtered = append(filtered, &copied)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return services
+	}
+	return filtered
+}
+
+// track records result of call to selected node
+func (h *nodeHealth) track(service string) client.CallWrapper {
+	return func(next client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			err := next(ctx, node, req, rsp, opts)
+			h.record(service, node.Id, err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/registry"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func ejectedGauge(t *testing.T, service string) float64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := ejectedNodes.WithLabelValues(service).Write(&m); err != nil {
+		t.Fatalf("read gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func selected(h *nodeHealth, nodes ...string) int {
+	s := registry.Service{Name: "test"}
+	for _, n := range nodes {
+		s.Nodes = append(s.Nodes, &registry.Node{Id: n})
+	}
+	return len(h.filter([]*registry.Service{&s})[0].Nodes)
+}
+
+func TestEjectedNodesGauge(t *testing.T) {
+	const service = "test.eject"
+	h := newNodeHealth(EjectConfig{Failures: 2, Duration: 1})
+	unavailable := errors.New("test", "unavailable", 503)
+
+	h.record(service, "n1", unavailable)
+	if ejectedGauge(t, service) != 0 || selected(h, "n1", "n2") != 2 {
+		t.Fatal("node is ejected before failures threshold")
+	}
+
+	h.record(service, "n1", unavailable)
+	h.record(service, "n1", unavailable)
+	if ejectedGauge(t, service) != 1 || selected(h, "n1", "n2") != 1 {
+		t.Fatal("node is not ejected after consecutive failures")
+	}
+
+	h.mu.Lock()
+	h.nodes["n1"].ejectedTill = time.Now().Add(-time.Millisecond)
+	h.mu.Unlock()
+
+	if selected(h, "n1", "n2") != 2 || ejectedGauge(t, service) != 0 {
+		t.Fatal("node is not readmitted after ejection duration")
+	}
+
+	// readmitted node is ejected by first failure
+	h.record(service, "n1", unavailable)
+	if ejectedGauge(t, service) != 1 || selected(h, "n1", "n2") != 1 {
+		t.Fatal("readmitted node is not ejected again")
+	}
+
+	h.record(service, "n1", nil)
+	if ejectedGauge(t, service) != 0 || selected(h, "n1", "n2") != 2 {
+		t.Fatal("node is not readmitted after successful call")
+	}
+}
@@ -2,21 +2,30 @@ package client
 
 import (
 	"context"
-	"fmt"
+	"crypto/rsa"
 
-	"github.com/Mikhalevich/filesharing-auth-service/pkg/token"
+	"github.com/Mikhalevich/filesharing/pkg/jwks"
 	"github.com/Mikhalevich/filesharing/pkg/proto/auth"
 )
 
 type GRPCAuthServiceClient struct {
 	client  auth.AuthService
-	decoder token.Decoder
+	decoder decoder
 }
 
-func NewGRPCAuthServiceClient(c auth.AuthService) (*GRPCAuthServiceClient, error) {
-	dec, err := token.NewRSADecoder()
-	if err != nil {
-		return nil, fmt.Errorf("unable to crate rsa decoder: %w", err)
+// NewGRPCAuthServiceClient create new client
+// tokens are verified with keys from set, embedded key is used if set is nil
+func NewGRPCAuthServiceClient(c auth.AuthService, keys *jwks.Set) (*GRPCAuthServiceClient, error) {
+	var dec decoder = &keySetDecoder{
+		keys: keys,
+	}
+
+	if keys == nil {
+		embedded, err := newEmbeddedDecoder()
+		if err != nil {
+			return nil, err
+		}
+		dec = embedded
 	}
 
 	return &GRPCAuthServiceClient{
@@ -25,6 +34,16 @@ func NewGRPCAuthServiceClient(c auth.AuthService) (*GRPCAuthServiceClient, error
 	}, nil
 }
 
+// PublicKeys fetches json web key set from auth service
+func PublicKeys(c auth.AuthService) (map[string]*rsa.PublicKey, error) {
+	rsp, err := c.PublicKeys(context.Background(), &auth.PublicKeysRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return jwks.Parse([]byte(rsp.GetJwks()))
+}
+
 func (c *GRPCAuthServiceClient) Create(user *auth.User) (*auth.Token, error) {
 	rsp, err := c.client.Create(context.Background(), &auth.CreateUserRequest{
 		User: user,
@@ -35,14 +54,15 @@ func (c *GRPCAuthServiceClient) Create(user *auth.User) (*auth.Token, error) {
 	return rsp.GetToken(), nil
 }
 
-func (c *GRPCAuthServiceClient) Auth(user *auth.User) (*auth.Token, error) {
+// Auth returns token or mfa challenge if user enrolled into mfa
+func (c *GRPCAuthServiceClient) Auth(user *auth.User) (*auth.Token, *auth.MFAChallenge, error) {
 	rsp, err := c.client.Auth(context.Background(), &auth.AuthUserRequest{
 		User: user,
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return rsp.GetToken(), nil
+	return rsp.GetToken(), rsp.GetChallenge(), nil
 }
 
 func (c *GRPCAuthServiceClient) AuthPublicUser(name string) (*auth.Token, error) {
@@ -55,6 +75,115 @@ func (c *GRPCAuthServiceClient) AuthPublicUser(name string) (*auth.Token, error)
 	return rsp.GetToken(), nil
 }
 
+func (c *GRPCAuthServiceClient) ChangePassword(name, oldPassword, newPassword string) (*auth.Token, error) {
+	rsp, err := c.client.ChangePassword(context.Background(), &auth.ChangePasswordRequest{
+		Name:        name,
+		OldPassword: oldPassword,
+		NewPassword: newPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.GetToken(), nil
+}
+
+func (c *GRPCAuthServiceClient) RequestPasswordReset(name string) (*auth.RequestPasswordResetResponse, error) {
+	rsp, err := c.client.RequestPasswordReset(context.Background(), &auth.RequestPasswordResetRequest{
+		Name: name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+func (c *GRPCAuthServiceClient) ResetPassword(name, resetToken, newPassword string) (*auth.Token, error) {
+	rsp, err := c.client.ResetPassword(context.Background(), &auth.ResetPasswordRequest{
+		Name:        name,
+		ResetToken:  resetToken,
+		NewPassword: newPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.GetToken(), nil
+}
+
+func (c *GRPCAuthServiceClient) EnrollMFA(name string) (*auth.EnrollMFAResponse, error) {
+	rsp, err := c.client.EnrollMFA(context.Background(), &auth.EnrollMFARequest{
+		Name: name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+func (c *GRPCAuthServiceClient) ConfirmMFA(name, code string) (*auth.Token, error) {
+	rsp, err := c.client.ConfirmMFA(context.Background(), &auth.ConfirmMFARequest{
+		Name: name,
+		Code: code,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.GetToken(), nil
+}
+
+func (c *GRPCAuthServiceClient) DisableMFA(name string) (*auth.Token, error) {
+	rsp, err := c.client.DisableMFA(context.Background(), &auth.DisableMFARequest{
+		Name: name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.GetToken(), nil
+}
+
+func (c *GRPCAuthServiceClient) VerifyMFA(name, challengeID, code string) (*auth.Token, error) {
+	rsp, err := c.client.VerifyMFA(context.Background(), &auth.VerifyMFARequest{
+		Name:        name,
+		ChallengeID: challengeID,
+		Code:        code,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.GetToken(), nil
+}
+
+// MFAEnrolled reports current enrollment state, token claims may be issued before enrollment
+func (c *GRPCAuthServiceClient) MFAEnrolled(name string) (bool, error) {
+	rsp, err := c.client.MFAStatus(context.Background(), &auth.MFAStatusRequest{
+		Name: name,
+	})
+	if err != nil {
+		return false, err
+	}
+	return rsp.GetEnrolled(), nil
+}
+
+func (c *GRPCAuthServiceClient) Delete(user *auth.User) error {
+	_, err := c.client.Delete(context.Background(), &auth.DeleteUserRequest{
+		User: user,
+	})
+	return err
+}
+
+// ExistingUsers returns names of existing users among requested ones
+// anonymous public users are included, their storages are not orphaned
+func (c *GRPCAuthServiceClient) ExistingUsers(names []string) ([]string, error) {
+	rsp, err := c.client.ExistingUsers(context.Background(), &auth.ExistingUsersRequest{
+		Names:         names,
+		IncludePublic: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp.GetNames(), nil
+}
+
 func (c *GRPCAuthServiceClient) UserByToken(tokenString string) (*auth.User, error) {
 	claims, err := c.decoder.Decode(tokenString)
 	if err != nil {
@@ -62,8 +191,10 @@ func (c *GRPCAuthServiceClient) UserByToken(tokenString string) (*auth.User, err
 	}
 
 	return &auth.User{
-		Id:     claims.User.ID,
-		Name:   claims.User.Name,
-		Public: claims.User.Public,
+		Id:          claims.User.ID,
+		Name:        claims.User.Name,
+		Public:      claims.User.Public,
+		MfaVerified: claims.MFA,
+		MfaEnrolled: claims.MFAEnrolled || claims.MFA,
 	}, nil
 }
@@ -4,19 +4,36 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
+	"github.com/Mikhalevich/filesharing/pkg/listing"
+	"github.com/Mikhalevich/filesharing/pkg/mimetype"
 	"github.com/Mikhalevich/filesharing/pkg/proto/file"
 )
 
+const (
+	defaultChunkSize = 64 * 1024
+)
+
 // GRPCFileServiceClient it's just wrapper around grpc FileServiceClient
 type GRPCFileServiceClient struct {
-	client file.FileService
+	client     file.FileService
+	chunkSize  int
+	resilience *Resilience
 }
 
 // NewGRPCFileServiceClient create new client
-func NewGRPCFileServiceClient(c file.FileService) *GRPCFileServiceClient {
+// zero chunk size means default one
+// resilience retries file download until the first chunk is received
+func NewGRPCFileServiceClient(c file.FileService, chunkSize int, r *Resilience) *GRPCFileServiceClient {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
 	return &GRPCFileServiceClient{
-		client: c,
+		client:     c,
+		chunkSize:  chunkSize,
+		resilience: r,
 	}
 }
 
@@ -30,6 +47,21 @@ func (c *GRPCFileServiceClient) Files(storage string, isPermanent bool) ([]*file
 	return rsp.GetFiles(), nil
 }
 
+// FilesPage returns single page of files sorted and filtered according to request
+func (c *GRPCFileServiceClient) FilesPage(req *file.ListRequest) (*file.ListResponse, error) {
+	rsp, err := c.client.List(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	// file service without pagination support returns whole list
+	if !rsp.GetPaginated() {
+		return listing.Paginate(rsp.GetFiles(), req)
+	}
+
+	return rsp, nil
+}
+
 // CreateStorage just create storage with specified storage name and permanent folder
 func (c *GRPCFileServiceClient) Create(storage string, withPermanent bool) error {
 	if _, err := c.client.CreateStorage(context.Background(), &file.CreateStorageRequest{
@@ -41,6 +73,39 @@ func (c *GRPCFileServiceClient) Create(storage string, withPermanent bool) error
 	return nil
 }
 
+// IsStorageExists checks storage for existence
+func (c *GRPCFileServiceClient) IsStorageExists(storage string) (bool, error) {
+	rsp, err := c.client.IsStorageExists(context.Background(), &file.IsStorageExistsRequest{
+		Name: storage,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rsp.GetFlag(), nil
+}
+
+// RemoveStorage removes storage with temporary and permanent files
+func (c *GRPCFileServiceClient) RemoveStorage(storage string) error {
+	_, err := c.client.RemoveStorage(context.Background(), &file.RemoveStorageRequest{
+		Name: storage,
+	})
+
+	return err
+}
+
+// RemoveUnownedStorage removes storage without registered or anonymous public user
+// file service checks the owner under storage lock, so storage can't be created for new user meanwhile
+// conflict error is returned for owned storage
+func (c *GRPCFileServiceClient) RemoveUnownedStorage(storage string) error {
+	_, err := c.client.RemoveStorage(context.Background(), &file.RemoveStorageRequest{
+		Name:        storage,
+		OnlyUnowned: true,
+	})
+
+	return err
+}
+
 // Remove remove file with fileName from storage
 func (c *GRPCFileServiceClient) Remove(storage string, isPermanent bool, fileName string) error {
 	_, err := c.client.RemoveFile(context.Background(), &file.FileRequest{
@@ -52,75 +117,214 @@ func (c *GRPCFileServiceClient) Remove(storage string, isPermanent bool, fileNam
 	return err
 }
 
-// Get download file from storage
-func (c *GRPCFileServiceClient) Get(storage string, isPermanent bool, fileName string, w io.Writer) error {
-	stream, err := c.client.GetFile(context.Background(), &file.FileRequest{
+// Trash moves file with fileName to storage trash
+func (c *GRPCFileServiceClient) Trash(storage string, isPermanent bool, fileName string) (*file.File, error) {
+	return c.client.MoveToTrash(context.Background(), &file.FileRequest{
+		Storage:     storage,
+		IsPermanent: isPermanent,
+		FileName:    fileName,
+	})
+}
+
+// TrashFiles return files from storage trash
+func (c *GRPCFileServiceClient) TrashFiles(storage string, isPermanent bool) ([]*file.File, error) {
+	rsp, err := c.client.ListTrash(context.Background(), &file.ListRequest{Storage: storage, IsPermanent: isPermanent})
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp.GetFiles(), nil
+}
+
+// RestoreFromTrash moves file back from trash
+// zero deletedAt restores the most recently deleted file with fileName
+func (c *GRPCFileServiceClient) RestoreFromTrash(storage string, isPermanent bool, fileName string, deletedAt int64) (*file.File, error) {
+	return c.client.RestoreFromTrash(context.Background(), &file.FileRequest{
 		Storage:     storage,
 		IsPermanent: isPermanent,
 		FileName:    fileName,
+		DeletedAt:   deletedAt,
 	})
+}
+
+// EmptyTrash removes all files from storage trash
+func (c *GRPCFileServiceClient) EmptyTrash(storage string, isPermanent bool) (int64, error) {
+	rsp, err := c.client.EmptyTrash(context.Background(), &file.ListRequest{Storage: storage, IsPermanent: isPermanent})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	for {
-		chunk, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			break
-		} else if err != nil {
-			return err
-		}
+	return rsp.GetRemoved(), nil
+}
+
+// Storages returns names of all storages
+func (c *GRPCFileServiceClient) Storages() ([]string, error) {
+	rsp, err := c.client.ListStorages(context.Background(), &file.ListStoragesRequest{})
+	if err != nil {
+		return nil, err
+	}
 
-		_, err = w.Write(chunk.Content)
+	return rsp.GetNames(), nil
+}
+
+// Get download file from storage
+func (c *GRPCFileServiceClient) Get(storage string, isPermanent bool, fileName string, w io.Writer) error {
+	return c.get(&file.FileRequest{
+		Storage:     storage,
+		IsPermanent: isPermanent,
+		FileName:    fileName,
+	}, w)
+}
+
+// GetVersion download specific file version from permanent storage
+func (c *GRPCFileServiceClient) GetVersion(storage string, fileName string, version int64, w io.Writer) error {
+	return c.get(&file.FileRequest{
+		Storage:     storage,
+		IsPermanent: true,
+		FileName:    fileName,
+		Version:     version,
+	}, w)
+}
+
+// contentTyper receives stored content type of file before its content
+type contentTyper interface {
+	SetContentType(contentType string)
+}
+
+// get writes file content into w, stored content type comes with the first chunk
+func (c *GRPCFileServiceClient) get(req *file.FileRequest, w io.Writer) error {
+	return c.resilience.Retry(context.Background(), "FileService.GetFile", func(ctx context.Context) (bool, error) {
+		stream, err := c.client.GetFile(ctx, req)
 		if err != nil {
-			return err
+			return false, err
 		}
+		defer stream.Close()
+
+		received := false
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return received, err
+			}
+			received = true
+
+			if ct := chunk.GetContentType(); ct != "" {
+				if t, ok := w.(contentTyper); ok {
+					t.SetContentType(ct)
+				}
+			}
+
+			_, err = w.Write(chunk.Content)
+			if err != nil {
+				return true, err
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// Versions return all versions of file from permanent storage
+func (c *GRPCFileServiceClient) Versions(storage string, fileName string) ([]*file.File, error) {
+	rsp, err := c.client.ListVersions(context.Background(), &file.FileRequest{
+		Storage:     storage,
+		IsPermanent: true,
+		FileName:    fileName,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return rsp.GetFiles(), nil
+}
+
+// RestoreVersion makes specified version the latest one
+func (c *GRPCFileServiceClient) RestoreVersion(storage string, fileName string, version int64) (*file.File, error) {
+	return c.client.RestoreVersion(context.Background(), &file.FileRequest{
+		Storage:     storage,
+		IsPermanent: true,
+		FileName:    fileName,
+		Version:     version,
+	})
+}
+
+// PruneVersions removes old versions of file
+// keeps at most keep versions and removes versions older than maxAge, zero value disables rule
+func (c *GRPCFileServiceClient) PruneVersions(storage string, fileName string, keep int, maxAge time.Duration) (int64, error) {
+	rsp, err := c.client.PruneVersions(context.Background(), &file.PruneVersionsRequest{
+		Storage:  storage,
+		FileName: fileName,
+		Keep:     int64(keep),
+		MaxAge:   int64(maxAge / time.Second),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rsp.GetRemoved(), nil
 }
 
 // Upload upload file to storage
-func (c *GRPCFileServiceClient) Upload(storage string, isPermanent bool, fileName string, r io.Reader) (*file.File, error) {
+// file is removed from temporary storage after ttl, zero ttl means storage default
+// content type is detected by file name and content sniffing
+// conflict defines what to do when file already exists, returned file has the final stored name
+func (c *GRPCFileServiceClient) Upload(storage string, isPermanent bool, fileName string, ttl time.Duration, conflict file.ConflictPolicy, r io.Reader) (*file.File, error) {
+	contentType, r, err := mimetype.DetectReader(fileName, r)
+	if err != nil {
+		return nil, err
+	}
+
 	stream, err := c.client.UploadFile(context.Background())
 	if err != nil {
 		return nil, err
 	}
+	defer stream.Close()
 
-	stream.Send(&file.FileUploadRequest{
+	if err := sendUpload(stream, &file.FileUploadRequest{
 		FileChunk: &file.FileUploadRequest_Metadata{
 			Metadata: &file.FileRequest{
 				Storage:     storage,
 				IsPermanent: isPermanent,
 				FileName:    fileName,
+				Ttl:         int64(ttl / time.Second),
+				ContentType: contentType,
+				Conflict:    conflict,
 			},
 		},
-	})
+	}); err != nil {
+		return nil, err
+	}
 
-	buf := make([]byte, 4096)
+	buf := make([]byte, c.chunkSize)
 	for {
-		n, err := r.Read(buf)
+		n, err := readChunk(r, buf)
+		if err != nil && !errors.Is(err, io.EOF) {
+			// stream is closed without end marker, so file service discards uploaded part
+			return nil, err
+		}
+
 		if n > 0 {
-			stream.Send(&file.FileUploadRequest{
+			if err := sendUpload(stream, &file.FileUploadRequest{
 				FileChunk: &file.FileUploadRequest_Content{
 					Content: buf[:n],
 				},
-			})
+			}); err != nil {
+				return nil, err
+			}
 		}
+
 		if errors.Is(err, io.EOF) {
 			break
-		} else if err != nil {
-			return nil, err
 		}
 	}
 
-	err = stream.Send(&file.FileUploadRequest{
+	if err := sendUpload(stream, &file.FileUploadRequest{
 		FileChunk: &file.FileUploadRequest_End{
 			End: true,
 		},
-	})
-
-	if err != nil {
+	}); err != nil {
 		return nil, err
 	}
 
@@ -131,3 +335,29 @@ func (c *GRPCFileServiceClient) Upload(storage string, isPermanent bool, fileNam
 
 	return f, nil
 }
+
+// readChunk fills buf like io.ReadFull but returns reader error as is
+// only io.EOF ends upload, io.ErrUnexpectedEOF of truncated multipart part is not taken for short last chunk
+func readChunk(r io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// sendUpload sends upload request
+// io.EOF means stream was aborted by file service, real error is received from stream then
+func sendUpload(stream file.FileService_UploadFileService, req *file.FileUploadRequest) error {
+	err := stream.Send(req)
+	if errors.Is(err, io.EOF) {
+		if _, recvErr := stream.Recv(); recvErr != nil && !errors.Is(recvErr, io.EOF) {
+			return recvErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+
+	"github.com/Mikhalevich/filesharing/pkg/proto/event"
+	"github.com/Mikhalevich/filesharing/pkg/proto/history"
+)
+
+// GRPCHistoryServiceClient it's just wrapper around grpc HistoryServiceClient
+type GRPCHistoryServiceClient struct {
+	client history.HistoryService
+}
+
+// NewGRPCHistoryServiceClient create new client
+func NewGRPCHistoryServiceClient(c history.HistoryService) *GRPCHistoryServiceClient {
+	return &GRPCHistoryServiceClient{
+		client: c,
+	}
+}
+
+// List return file events for user
+func (c *GRPCHistoryServiceClient) List(userID int64) ([]*event.FileEvent, error) {
+	rsp, err := c.client.List(context.Background(), &history.ListRequest{
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp.GetFiles(), nil
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics are shared by all clients of the process and labeled by service or endpoint
+var (
+	breakerStateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "filesharing_rpc_circuit_breaker_state",
+		Help: "Circuit breaker state by service: 0 - closed, 1 - half open, 2 - open.",
+	}, []string{"service"})
+
+	breakerRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "filesharing_rpc_circuit_breaker_rejected_total",
+		Help: "Requests rejected by circuit breaker.",
+	}, []string{"service"})
+
+	ejectedNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "filesharing_rpc_ejected_nodes",
+		Help: "Nodes ejected from selection after consecutive failures and not recovered yet.",
+	}, []string{"service"})
+
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "filesharing_rpc_retries_total",
+		Help: "Retried requests by endpoint.",
+	}, []string{"endpoint"})
+
+	hedgedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "filesharing_rpc_hedged_total",
+		Help: "Hedged requests by endpoint.",
+	}, []string{"endpoint"})
+)
+
+func init() {
+	prometheus.MustRegister(breakerStateGauge, breakerRejectedTotal, ejectedNodes, retriesTotal, hedgedTotal)
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/asim/go-micro/v3/client"
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/asim/go-micro/v3/selector"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultBackoffBase = 50 * time.Millisecond
+	defaultBackoffMax  = time.Second
+)
+
+// Policy retries and hedging of idempotent rpc method, durations are in milliseconds
+// retries is amount of extra attempts, hedge_delay is time before duplicate request is sent, zero disables it
+type Policy struct {
+	Retries     int `yaml:"retries"`
+	BackoffBase int `yaml:"backoff_base"`
+	BackoffMax  int `yaml:"backoff_max"`
+	HedgeDelay  int `yaml:"hedge_delay"`
+}
+
+// ResilienceConfig rpc client retries, circuit breaker per service and node ejection
+// methods override default policies by endpoint like FileService.List
+type ResilienceConfig struct {
+	Breaker BreakerConfig     `yaml:"breaker"`
+	Eject   EjectConfig       `yaml:"eject"`
+	Methods map[string]Policy `yaml:"methods"`
+}
+
+// defaultPolicies retries for idempotent methods, other methods are called once
+func defaultPolicies() map[string]Policy {
+	retry := Policy{Retries: 2}
+	return map[string]Policy{
+		"FileService.List":            retry,
+		"FileService.ListTrash":       retry,
+		"FileService.ListVersions":    retry,
+		"FileService.GetFile":         retry,
+		"FileService.IsStorageExists": retry,
+		"FileService.ListStorages":    retry,
+		"AuthService.PublicKeys":      retry,
+		"AuthService.ExistingUsers":   retry,
+		"HistoryService.List":         retry,
+	}
+}
+
+func (c ResilienceConfig) Validate() error {
+	if err := c.Breaker.Validate(); err != nil {
+		return fmt.Errorf("breaker: %w", err)
+	}
+
+	if err := c.Eject.Validate(); err != nil {
+		return fmt.Errorf("eject: %w", err)
+	}
+
+	for method, p := range c.Methods {
+		if p.Retries < 0 || p.BackoffBase < 0 || p.BackoffMax < 0 || p.HedgeDelay < 0 {
+			return fmt.Errorf("invalid policy for method %s", method)
+		}
+	}
+
+	return nil
+}
+
+// isRetryable reports transport failures, errors returned by service handlers are final
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	e := errors.FromError(err)
+	if e.Id == breakerErrorID {
+		return false
+	}
+
+	switch e.Code {
+	case 408, 502, 503, 504:
+		return true
+	case 500:
+		return e.Id == "go.micro.client"
+	}
+	return false
+}
+
+// Resilience applies retries, hedging, circuit breakers and node ejection to rpc calls
+type Resilience struct {
+	policies map[string]Policy
+	breakers *breakers
+	nodes    *nodeHealth
+}
+
+func NewResilience(c ResilienceConfig) *Resilience {
+	policies := defaultPolicies()
+	for method, p := range c.Methods {
+		policies[method] = p
+	}
+
+	return &Resilience{
+		policies: policies,
+		breakers: newBreakers(c.Breaker),
+		nodes:    newNodeHealth(c.Eject),
+	}
+}
+
+func (r *Resilience) policy(endpoint string) Policy {
+	return r.policies[endpoint]
+}
+
+// backoff returns random delay up to exponentially growing limit
+func backoff(p Policy, attempt int) time.Duration {
+	base := defaultBackoffBase
+	if p.BackoffBase > 0 {
+		base = time.Duration(p.BackoffBase) * time.Millisecond
+	}
+
+	max := defaultBackoffMax
+	if p.BackoffMax > 0 {
+		max = time.Duration(p.BackoffMax) * time.Millisecond
+	}
+
+	limit := base << uint(attempt)
+	if limit > max || limit <= 0 {
+		limit = max
+	}
+
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
+
+// Retry calls fn until success or non retryable error according to endpoint policy
+// fn reports progress, call with partially returned result is not retried
+func (r *Resilience) Retry(ctx context.Context, endpoint string, fn func(ctx context.Context) (bool, error)) error {
+	p := r.policy(endpoint)
+
+	for attempt := 0; ; attempt++ {
+		progressed, err := fn(ctx)
+		if err == nil || progressed || attempt >= p.Retries || !isRetryable(err) {
+			return err
+		}
+
+		retriesTotal.WithLabelValues(endpoint).Inc()
+
+		timer := time.NewTimer(backoff(p, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Wrap returns go-micro client wrapper
+// unary calls are retried, hedged and guarded by circuit breaker,
+// streams are guarded by circuit breaker on creation only
+func (r *Resilience) Wrap(c client.Client) client.Client {
+	return &resilientClient{
+		Client: c,
+		r:      r,
+	}
+}
+
+type resilientClient struct {
+	client.Client
+	r *Resilience
+}
+
+func (c *resilientClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	service := req.Service()
+	endpoint := req.Endpoint()
+	p := c.r.policy(endpoint)
+	b := c.r.breakers.get(service)
+
+	opts = append(opts,
+		client.WithSelectOption(selector.WithFilter(c.r.nodes.filter)),
+		client.WithCallWrapper(c.r.nodes.track(service)),
+	)
+	if p.Retries > 0 {
+		// retries are made here, so go-micro ones are disabled to not multiply attempts
+		opts = append(opts, client.WithRetries(0))
+	}
+
+	return c.r.Retry(ctx, endpoint, func(ctx context.Context) (bool, error) {
+		if err := b.allow(); err != nil {
+			return false, err
+		}
+
+		var err error
+		if p.HedgeDelay > 0 {
+			err = c.hedgedCall(ctx, req, rsp, time.Duration(p.HedgeDelay)*time.Millisecond, opts)
+		} else {
+			err = c.Client.Call(ctx, req, rsp, opts...)
+		}
+
+		b.record(err)
+		return false, err
+	})
+}
+
+// hedgedCall sends duplicate request if first one is not completed within delay
+// the first successful response wins, the other request is cancelled
+func (c *resilientClient) hedgedCall(ctx context.Context, req client.Request, rsp interface{}, delay time.Duration, opts []client.CallOption) error {
+	msg, ok := rsp.(proto.Message)
+	if !ok {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		rsp proto.Message
+		err error
+	}
+	results := make(chan result, 2)
+
+	call := func() {
+		r := msg.ProtoReflect().New().Interface()
+		results <- result{rsp: r, err: c.Client.Call(ctx, req, r, opts...)}
+	}
+
+	go call()
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			hedgedTotal.WithLabelValues(req.Endpoint()).Inc()
+			pending++
+			go call()
+
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				proto.Reset(msg)
+				proto.Merge(msg, res.rsp)
+				return nil
+			}
+
+			if pending == 0 {
+				return res.err
+			}
+		}
+	}
+}
+
+func (c *resilientClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	b := c.r.breakers.get(req.Service())
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, client.WithSelectOption(selector.WithFilter(c.r.nodes.filter)))
+	s, err := c.Client.Stream(ctx, req, opts...)
+	b.record(err)
+	return s, err
+}
@@ -0,0 +1,203 @@
+// Package memory implements broker delivering messages within single process
+package memory
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+
+	"github.com/asim/go-micro/v3/broker"
+)
+
+var (
+	errNotConnected = errors.New("broker is not connected")
+)
+
+// Broker delivers messages synchronously to subscribers of the same broker
+// subscribers with the same queue receive every message once per queue
+type Broker struct {
+	opts broker.Options
+
+	mu          sync.RWMutex
+	connected   bool
+	lastID      int64
+	subscribers map[string][]*subscriber
+}
+
+func NewBroker(opts ...broker.Option) *Broker {
+	b := Broker{
+		subscribers: make(map[string][]*subscriber),
+	}
+	b.Init(opts...)
+	return &b
+}
+
+func (b *Broker) Init(opts ...broker.Option) error {
+	for _, o := range opts {
+		o(&b.opts)
+	}
+	return nil
+}
+
+func (b *Broker) Options() broker.Options {
+	return b.opts
+}
+
+func (b *Broker) Address() string {
+	return "memory"
+}
+
+func (b *Broker) Connect() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connected = true
+	return nil
+}
+
+// IsConnected reports whether Connect was called, used by readiness check
+func (b *Broker) IsConnected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.connected
+}
+
+// Disconnect keeps subscribers, broker is shared between services of the process
+func (b *Broker) Disconnect() error {
+	return nil
+}
+
+func (b *Broker) Publish(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+	b.mu.RLock()
+	if !b.connected {
+		b.mu.RUnlock()
+		return errNotConnected
+	}
+
+	var (
+		receivers []*subscriber
+		queues    = make(map[string][]*subscriber)
+	)
+	for _, s := range b.subscribers[topic] {
+		if q := s.opts.Queue; q != "" {
+			queues[q] = append(queues[q], s)
+			continue
+		}
+		receivers = append(receivers, s)
+	}
+	b.mu.RUnlock()
+
+	for _, subs := range queues {
+		receivers = append(receivers, subs[rand.Intn(len(subs))])
+	}
+
+	var lastErr error
+	for _, s := range receivers {
+		e := event{
+			topic:   topic,
+			message: copyMessage(m),
+		}
+
+		if err := s.handler(&e); err != nil {
+			e.err = err
+			lastErr = err
+			if eh := b.opts.ErrorHandler; eh != nil {
+				eh(&e)
+			}
+		}
+	}
+
+	return lastErr
+}
+
+func (b *Broker) Subscribe(topic string, h broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.connected {
+		return nil, errNotConnected
+	}
+
+	b.lastID++
+	s := subscriber{
+		id:      b.lastID,
+		topic:   topic,
+		handler: h,
+		opts:    broker.NewSubscribeOptions(opts...),
+		broker:  b,
+	}
+	b.subscribers[topic] = append(b.subscribers[topic], &s)
+
+	return &s, nil
+}
+
+func (b *Broker) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subscribers[s.topic]
+	for i, sub := range subs {
+		if sub.id == s.id {
+			b.subscribers[s.topic] = append(subs[:i:i], subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *Broker) String() string {
+	return "memory"
+}
+
+func copyMessage(m *broker.Message) *broker.Message {
+	header := make(map[string]string, len(m.Header))
+	for k, v := range m.Header {
+		header[k] = v
+	}
+
+	return &broker.Message{
+		Header: header,
+		Body:   append([]byte(nil), m.Body...),
+	}
+}
+
+type subscriber struct {
+	id      int64
+	topic   string
+	handler broker.Handler
+	opts    broker.SubscribeOptions
+	broker  *Broker
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *subscriber) Topic() string {
+	return s.topic
+}
+
+func (s *subscriber) Unsubscribe() error {
+	s.broker.unsubscribe(s)
+	return nil
+}
+
+type event struct {
+	topic   string
+	message *broker.Message
+	err     error
+}
+
+func (e *event) Topic() string {
+	return e.topic
+}
+
+func (e *event) Message() *broker.Message {
+	return e.message
+}
+
+func (e *event) Ack() error {
+	return nil
+}
+
+func (e *event) Error() error {
+	return e.err
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/asim/go-micro/v3/broker"
+)
+
+func connected(t *testing.T, opts ...broker.Option) *Broker {
+	t.Helper()
+
+	b := NewBroker(opts...)
+	if err := b.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return b
+}
+
+func counter(count *int) broker.Handler {
+	return func(e broker.Event) error {
+		*count++
+		return nil
+	}
+}
+
+func TestNotConnected(t *testing.T) {
+	b := NewBroker()
+
+	if b.IsConnected() {
+		t.Fatal("broker is connected before connect")
+	}
+
+	if err := b.Publish("topic", &broker.Message{}); !errors.Is(err, errNotConnected) {
+		t.Fatalf("expected not connected error on publish, got %v", err)
+	}
+
+	if _, err := b.Subscribe("topic", counter(new(int))); !errors.Is(err, errNotConnected) {
+		t.Fatalf("expected not connected error on subscribe, got %v", err)
+	}
+
+	b.Connect()
+	if !b.IsConnected() {
+		t.Fatal("broker is not connected after connect")
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	b := connected(t)
+
+	var first, second, other int
+	b.Subscribe("topic", counter(&first))
+	s, _ := b.Subscribe("topic", counter(&second))
+	b.Subscribe("other", counter(&other))
+
+	if err := b.Publish("topic", &broker.Message{}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if first != 1 || second != 1 || other != 0 {
+		t.Fatalf("unexpected deliveries: %d %d %d", first, second, other)
+	}
+
+	if s.Topic() != "topic" {
+		t.Fatalf("unexpected topic %q", s.Topic())
+	}
+
+	s.Unsubscribe()
+	b.Publish("topic", &broker.Message{})
+
+	if first != 2 || second != 1 {
+		t.Fatalf("unexpected deliveries after unsubscribe: %d %d", first, second)
+	}
+
+	// disconnect keeps subscribers of other services in the process
+	b.Disconnect()
+	b.Publish("topic", &broker.Message{})
+
+	if first != 3 {
+		t.Fatalf("subscriber was dropped by disconnect")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	b := connected(t)
+
+	var q1, q2, other, plain int
+	b.Subscribe("topic", counter(&q1), broker.Queue("q"))
+	b.Subscribe("topic", counter(&q2), broker.Queue("q"))
+	b.Subscribe("topic", counter(&other), broker.Queue("other"))
+	b.Subscribe("topic", counter(&plain))
+
+	const messages = 100
+	for i := 0; i < messages; i++ {
+		if err := b.Publish("topic", &broker.Message{}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	if q1+q2 != messages {
+		t.Fatalf("queue received %d messages instead of %d", q1+q2, messages)
+	}
+
+	if other != messages || plain != messages {
+		t.Fatalf("unexpected deliveries: queue %d, plain %d", other, plain)
+	}
+}
+
+func TestMessageCopy(t *testing.T) {
+	b := connected(t)
+
+	b.Subscribe("topic", func(e broker.Event) error {
+		e.Message().Header["key"] = "changed"
+		e.Message().Body[0] = 'x'
+		return nil
+	})
+
+	var got *broker.Message
+	b.Subscribe("topic", func(e broker.Event) error {
+		got = e.Message()
+		return nil
+	})
+
+	m := broker.Message{
+		Header: map[string]string{"key": "value"},
+		Body:   []byte("body"),
+	}
+	b.Publish("topic", &m)
+
+	if m.Header["key"] != "value" || string(m.Body) != "body" {
+		t.Fatalf("published message was changed: %v %q", m.Header, m.Body)
+	}
+
+	if got.Header["key"] != "value" || string(got.Body) != "body" {
+		t.Fatalf("subscribers share message: %v %q", got.Header, got.Body)
+	}
+}
+
+func TestHandlerError(t *testing.T) {
+	var handled []error
+	b := connected(t, broker.ErrorHandler(func(e broker.Event) error {
+		handled = append(handled, e.Error())
+		return nil
+	}))
+
+	errHandler := errors.New("handler error")
+	b.Subscribe("topic", func(e broker.Event) error {
+		return errHandler
+	})
+
+	var delivered int
+	b.Subscribe("topic", counter(&delivered))
+
+	if err := b.Publish("topic", &broker.Message{}); !errors.Is(err, errHandler) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+
+	if delivered != 1 {
+		t.Fatal("failed handler stopped delivery")
+	}
+
+	if len(handled) != 1 || !errors.Is(handled[0], errHandler) {
+		t.Fatalf("error handler got %v", handled)
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Mikhalevich/filesharing/pkg/httperror"
+)
+
+// levelAuth allows requests with level token passed as bearer token
+// token is read on every request, so it's changed by configuration reload
+func levelAuth(token func() string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := token()
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if expected == "" || subtle.ConstantTimeCompare([]byte(got), []byte(expected)) != 1 {
+			httperror.NewUnauthorized("invalid level token").WriteJSON(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logLevelHandler shows and changes log level at runtime
+// GET returns current levels
+// PUT sets level for package or global one if package is omitted
+// DELETE removes package override
+// changes are discarded when log configuration is reloaded
+func (s *logState) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	pkg := r.FormValue("package")
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.FormValue("level") == "" {
+			httperror.NewInvalidParams("level was not set").WriteJSON(w)
+			return
+		}
+
+		level, err := parseLevel(r.FormValue("level"))
+		if err != nil {
+			httperror.NewInvalidParams("invalid level").WithError(err).WriteJSON(w)
+			return
+		}
+		s.setLevel(pkg, level)
+
+	case http.MethodDelete:
+		if pkg == "" {
+			httperror.NewInvalidParams("package was not set").WriteJSON(w)
+			return
+		}
+		s.removePackage(pkg)
+	}
+
+	level, packages := s.levels()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		Level    string            `json:"level"`
+		Packages map[string]string `json:"packages"`
+	}{
+		Level:    level,
+		Packages: packages,
+	})
+}
@@ -2,9 +2,18 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.elastic.co/ecslogrus"
+
+	"github.com/Mikhalevich/filesharing/pkg/ctxinfo"
 )
 
 type Logger interface {
@@ -23,71 +32,375 @@ type Logger interface {
 	WithFields(map[string]interface{}) Logger
 }
 
+func parseLevel(level string) (logrus.Level, error) {
+	switch level {
+	case "":
+		return logrus.InfoLevel, nil
+	case "debug", "info", "warn", "error":
+		return logrus.ParseLevel(level)
+	}
+	return 0, fmt.Errorf("unknown level: %q", level)
+}
+
+func makeFormatter(format string) logrus.Formatter {
+	switch format {
+	case "logfmt":
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	case "console":
+		return &logrus.TextFormatter{ForceColors: true, FullTimestamp: true}
+	}
+	return &ecslogrus.Formatter{}
+}
+
+func openOutput(output string) (io.Writer, error) {
+	switch output {
+	case "", "stderr":
+		return os.Stderr, nil
+	case "stdout":
+		return os.Stdout, nil
+	}
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	return f, nil
+}
+
+// sampler passes first initial messages with the same key every period and then every thereafter one
+type sampler struct {
+	mu         sync.Mutex
+	initial    int
+	thereafter int
+	period     time.Duration
+	reset      time.Time
+	counts     map[string]int
+}
+
+func newSampler(c SamplingConfig) *sampler {
+	if c.Initial <= 0 {
+		return nil
+	}
+
+	period := time.Second
+	if c.Period > 0 {
+		period = time.Duration(c.Period) * time.Second
+	}
+
+	return &sampler{
+		initial:    c.Initial,
+		thereafter: c.Thereafter,
+		period:     period,
+		counts:     make(map[string]int),
+	}
+}
+
+func (s *sampler) allow(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); now.After(s.reset) {
+		s.counts = make(map[string]int)
+		s.reset = now.Add(s.period)
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.initial {
+		return true
+	}
+
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+// logState is shared between logger and all loggers derived from it
+// logrus logs everything, levels are checked here to support per package overrides
+type logState struct {
+	logger *logrus.Logger
+
+	mu       sync.RWMutex
+	level    logrus.Level
+	packages map[string]logrus.Level
+	sampler  *sampler
+	file     io.Closer
+
+	// program counter to package path cache
+	callers sync.Map
+}
+
+func newLogState() *logState {
+	l := logrus.New()
+	l.SetFormatter(&ecslogrus.Formatter{})
+	l.SetLevel(logrus.TraceLevel)
+
+	return &logState{
+		logger: l,
+		level:  logrus.InfoLevel,
+	}
+}
+
+// configure applies configuration, runtime level changes are discarded
+func (s *logState) configure(c LogConfig) error {
+	level, err := parseLevel(c.Level)
+	if err != nil {
+		return err
+	}
+
+	packages := make(map[string]logrus.Level, len(c.Packages))
+	for pkg, l := range c.Packages {
+		packages[pkg], err = parseLevel(l)
+		if err != nil {
+			return fmt.Errorf("package %s: %w", pkg, err)
+		}
+	}
+
+	out, err := openOutput(c.Output)
+	if err != nil {
+		return err
+	}
+
+	s.logger.SetFormatter(makeFormatter(c.Format))
+	s.logger.SetOutput(out)
+
+	s.mu.Lock()
+	prevFile := s.file
+	s.file = nil
+	if f, ok := out.(*os.File); ok && f != os.Stdout && f != os.Stderr {
+		s.file = f
+	}
+	s.level = level
+	s.packages = packages
+	s.sampler = newSampler(c.Sampling)
+	s.mu.Unlock()
+
+	if prevFile != nil {
+		prevFile.Close()
+	}
+
+	return nil
+}
+
+func (s *logState) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil {
+		s.logger.SetOutput(os.Stderr)
+		s.file.Close()
+		s.file = nil
+	}
+}
+
+// setLevel changes global level or level of package if it's not empty
+func (s *logState) setLevel(pkg string, level logrus.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pkg == "" {
+		s.level = level
+		return
+	}
+
+	packages := make(map[string]logrus.Level, len(s.packages)+1)
+	for p, l := range s.packages {
+		packages[p] = l
+	}
+	packages[pkg] = level
+	s.packages = packages
+}
+
+func (s *logState) removePackage(pkg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packages := make(map[string]logrus.Level, len(s.packages))
+	for p, l := range s.packages {
+		if p != pkg {
+			packages[p] = l
+		}
+	}
+	s.packages = packages
+}
+
+func (s *logState) levels() (string, map[string]string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	packages := make(map[string]string, len(s.packages))
+	for p, l := range s.packages {
+		packages[p] = l.String()
+	}
+	return s.level.String(), packages
+}
+
+// callerPackage returns import path of package calling logger
+func (s *logState) callerPackage(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+
+	if pkg, ok := s.callers.Load(pc); ok {
+		return pkg.(string)
+	}
+
+	pkg := ""
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		// function name looks like github.com/user/repo/pkg.(*Type).Method
+		name := fn.Name()
+		slash := strings.LastIndex(name, "/")
+		if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+			pkg = name[:slash+1+dot]
+		}
+	}
+
+	s.callers.Store(pc, pkg)
+	return pkg
+}
+
+// packageLevel returns level of longest matching package override
+func packageLevel(packages map[string]logrus.Level, pkg string) (logrus.Level, bool) {
+	var (
+		level   logrus.Level
+		matched string
+	)
+	for p, l := range packages {
+		if (pkg == p || strings.HasSuffix(pkg, "/"+p)) && len(p) > len(matched) {
+			level = l
+			matched = p
+		}
+	}
+	return level, matched != ""
+}
+
+// allow checks message level and sampling, it should be called directly from logger method
+// args are used as sampling key for messages without format
+func (s *logState) allow(level logrus.Level, format string, args []interface{}) bool {
+	s.mu.RLock()
+	threshold := s.level
+	packages := s.packages
+	smp := s.sampler
+	s.mu.RUnlock()
+
+	if len(packages) > 0 {
+		// skip allow and logger method
+		if l, ok := packageLevel(packages, s.callerPackage(2)); ok {
+			threshold = l
+		}
+	}
+
+	if level > threshold {
+		return false
+	}
+
+	if smp == nil || level <= logrus.ErrorLevel {
+		return true
+	}
+
+	if format == "" {
+		format = fmt.Sprint(args...)
+	}
+	return smp.allow(level.String() + format)
+}
+
 type loggerWrapper struct {
 	l *logrus.Entry
+	s *logState
 }
 
 func newLoggerWrapper(name string) *loggerWrapper {
-	l := logrus.New()
-	l.SetFormatter(&ecslogrus.Formatter{})
+	s := newLogState()
 
 	return &loggerWrapper{
-		l: l.WithField("service_name", name),
+		l: s.logger.WithField("service_name", name),
+		s: s,
 	}
 }
 
 func (lw *loggerWrapper) Debugf(format string, args ...interface{}) {
-	lw.l.Debugf(format, args...)
+	if lw.s.allow(logrus.DebugLevel, format, args) {
+		lw.l.Debugf(format, args...)
+	}
 }
 
 func (lw *loggerWrapper) Infof(format string, args ...interface{}) {
-	lw.l.Infof(format, args...)
+	if lw.s.allow(logrus.InfoLevel, format, args) {
+		lw.l.Infof(format, args...)
+	}
 }
 
 func (lw *loggerWrapper) Warnf(format string, args ...interface{}) {
-	lw.l.Warnf(format, args...)
+	if lw.s.allow(logrus.WarnLevel, format, args) {
+		lw.l.Warnf(format, args...)
+	}
 }
 
 func (lw *loggerWrapper) Errorf(format string, args ...interface{}) {
-	lw.l.Errorf(format, args...)
+	if lw.s.allow(logrus.ErrorLevel, format, args) {
+		lw.l.Errorf(format, args...)
+	}
 }
 
 func (lw *loggerWrapper) Debug(args ...interface{}) {
-	lw.l.Debug(args...)
+	if lw.s.allow(logrus.DebugLevel, "", args) {
+		lw.l.Debug(args...)
+	}
 }
 
 func (lw *loggerWrapper) Info(args ...interface{}) {
-	lw.l.Info(args...)
+	if lw.s.allow(logrus.InfoLevel, "", args) {
+		lw.l.Info(args...)
+	}
 }
 
 func (lw *loggerWrapper) Warn(args ...interface{}) {
-	lw.l.Warn(args...)
+	if lw.s.allow(logrus.WarnLevel, "", args) {
+		lw.l.Warn(args...)
+	}
 }
 
 func (lw *loggerWrapper) Error(args ...interface{}) {
-	lw.l.Error(args...)
+	if lw.s.allow(logrus.ErrorLevel, "", args) {
+		lw.l.Error(args...)
+	}
 }
 
+// WithContext adds request id and user from context info
 func (lw *loggerWrapper) WithContext(ctx context.Context) Logger {
+	fields := logrus.Fields{}
+	if id, err := ctxinfo.RequestID(ctx); err == nil {
+		fields["request_id"] = id
+	}
+	if id, err := ctxinfo.UserID(ctx); err == nil {
+		fields["user_id"] = id
+	}
+	if name, err := ctxinfo.UserName(ctx); err == nil {
+		fields["user_name"] = name
+	}
+
 	return &loggerWrapper{
-		l: lw.l.WithContext(ctx),
+		l: lw.l.WithContext(ctx).WithFields(fields),
+		s: lw.s,
 	}
 }
 
 func (lw *loggerWrapper) WithError(err error) Logger {
 	return &loggerWrapper{
 		l: lw.l.WithError(err),
+		s: lw.s,
 	}
 }
 
 func (lw *loggerWrapper) WithField(key string, value interface{}) Logger {
 	return &loggerWrapper{
 		l: lw.l.WithField(key, value),
+		s: lw.s,
 	}
 }
 
 func (lw *loggerWrapper) WithFields(fields map[string]interface{}) Logger {
 	return &loggerWrapper{
 		l: lw.l.WithFields(fields),
+		s: lw.s,
 	}
 }
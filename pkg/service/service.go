@@ -12,12 +12,20 @@ func WithPostAction(fn func()) Option {
 	}
 }
 
+// WithReloadAction adds action called after reloadable configuration fields were updated
+func WithReloadAction(fn func()) Option {
+	return func(o *service) {
+		o.reloadActions = append(o.reloadActions, fn)
+	}
+}
+
 type service struct {
-	l           Logger
-	router      *mux.Router
-	postActions []func()
-	cm          *ClientManager
-	publisher   *Publisher
+	l             Logger
+	router        *mux.Router
+	postActions   []func()
+	reloadActions []func()
+	cm            *ClientManager
+	publisher     *Publisher
 }
 
 func (s *service) Logger() Logger {
@@ -45,3 +53,9 @@ func (s *service) runPostActions() {
 		action()
 	}
 }
+
+func (s *service) runReloadActions() {
+	for _, action := range s.reloadActions {
+		action()
+	}
+}
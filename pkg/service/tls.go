@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves certificate loaded from files and reloads it when files are changed
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if _, err := cr.reload(); err != nil {
+		return nil, err
+	}
+
+	return &cr, nil
+}
+
+func (cr *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat %s: %w", f, err)
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload loads certificate if files were changed since last load
+func (cr *certReloader) reload() (bool, error) {
+	modTime, err := cr.filesModTime()
+	if err != nil {
+		return false, err
+	}
+
+	cr.mu.RLock()
+	changed := cr.cert == nil || modTime.After(cr.modTime)
+	cr.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load key pair: %w", err)
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.mu.Unlock()
+
+	return true, nil
+}
+
+// watch checks files every interval until context is done
+// previous certificate is kept on error, because files are often replaced one by one
+func (cr *certReloader) watch(ctx context.Context, interval time.Duration, l Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := cr.reload()
+			if err != nil {
+				l.WithError(err).WithField("cert_file", cr.certFile).Error("unable to reload certificate")
+				continue
+			}
+
+			if changed {
+				l.WithField("cert_file", cr.certFile).Info("certificate reloaded")
+			}
+		}
+	}
+}
+
+func (cr *certReloader) certificate() *tls.Certificate {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.certificate(), nil
+}
+
+func (cr *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return cr.certificate(), nil
+}
+
+func loadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in ca file %s", path)
+	}
+
+	return pool, nil
+}
+
+// makeTLSConfig creates tls configuration with certificate reloaded in background
+// the same configuration is used for both listening and dialing by rpc transport
+func makeTLSConfig(ctx context.Context, c TLSConfig, rpc bool, l Logger) (*tls.Config, error) {
+	cr, err := newCertReloader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ReloadInterval > 0 {
+		go cr.watch(ctx, time.Duration(c.ReloadInterval)*time.Second, l)
+	}
+
+	cfg := tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.getCertificate,
+	}
+
+	if c.CAFile == "" {
+		return &cfg, nil
+	}
+
+	pool, err := loadCAPool(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+
+	if rpc {
+		cfg.RootCAs = pool
+		cfg.ServerName = c.ServerName
+		cfg.GetClientCertificate = cr.getClientCertificate
+	}
+
+	return &cfg, nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asim/go-micro/v3/transport"
+
+	"github.com/Mikhalevich/filesharing/pkg/service/tlstest"
+)
+
+func newTestCA(t *testing.T) (*tlstest.CA, string) {
+	t.Helper()
+
+	ca, err := tlstest.NewCA()
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+
+	caFile, err := ca.WriteCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+
+	return ca, caFile
+}
+
+func writeCert(t *testing.T, ca *tlstest.CA, dir string, name string) (string, string) {
+	t.Helper()
+
+	certFile, keyFile, err := ca.WriteFiles(dir, name, "localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func caPool(t *testing.T, ca *tlstest.CA) *x509.CertPool {
+	t.Helper()
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca.CertPEM()) {
+		t.Fatal("unable to add ca certificate")
+	}
+	return pool
+}
+
+func TestHTTPTLS(t *testing.T) {
+	ca, _ := newTestCA(t)
+	certFile, keyFile := writeCert(t, ca, t.TempDir(), "server")
+
+	cfg, err := makeTLSConfig(context.Background(), TLSConfig{CertFile: certFile, KeyFile: keyFile}, false, newLoggerWrapper("test"))
+	if err != nil {
+		t.Fatalf("make tls config: %v", err)
+	}
+
+	// httptest installs own certificate when config has none, so server is started by hand
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	srv := http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	url := "https://" + listener.Addr().String()
+
+	trusted := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: caPool(t, ca)}}}
+	rsp, err := trusted.Get(url)
+	if err != nil {
+		t.Fatalf("request with trusted ca: %v", err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rsp.StatusCode)
+	}
+
+	untrusted := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: x509.NewCertPool()}}}
+	if rsp, err := untrusted.Get(url); err == nil {
+		rsp.Body.Close()
+		t.Fatal("request without trusted ca succeeded")
+	}
+}
+
+func echo(t *testing.T, tr transport.Transport, addr string) error {
+	t.Helper()
+
+	c, err := tr.Dial(addr, transport.WithTimeout(5*time.Second))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Send(&transport.Message{Body: []byte("ping")}); err != nil {
+		return err
+	}
+
+	var m transport.Message
+	if err := c.Recv(&m); err != nil {
+		return err
+	}
+
+	if string(m.Body) != "ping" {
+		t.Fatalf("unexpected body: %q", m.Body)
+	}
+	return nil
+}
+
+func TestRPCMutualTLS(t *testing.T) {
+	ca, caFile := newTestCA(t)
+	dir := t.TempDir()
+	serverCert, serverKey := writeCert(t, ca, dir, "server")
+	clientCert, clientKey := writeCert(t, ca, dir, "client")
+
+	l := newLoggerWrapper("test")
+	serverCfg, err := makeTLSConfig(context.Background(), TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile, ServerName: "localhost"}, true, l)
+	if err != nil {
+		t.Fatalf("make server tls config: %v", err)
+	}
+
+	listener, err := transport.NewHTTPTransport(transport.TLSConfig(serverCfg)).Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	go listener.Accept(func(sock transport.Socket) {
+		for {
+			var m transport.Message
+			if err := sock.Recv(&m); err != nil {
+				return
+			}
+			if err := sock.Send(&m); err != nil {
+				return
+			}
+		}
+	})
+
+	clientCfg, err := makeTLSConfig(context.Background(), TLSConfig{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile, ServerName: "localhost"}, true, l)
+	if err != nil {
+		t.Fatalf("make client tls config: %v", err)
+	}
+
+	if err := echo(t, transport.NewHTTPTransport(transport.TLSConfig(clientCfg)), listener.Addr()); err != nil {
+		t.Fatalf("call with client certificate: %v", err)
+	}
+
+	noCert := &tls.Config{RootCAs: caPool(t, ca), ServerName: "localhost"}
+	if err := echo(t, transport.NewHTTPTransport(transport.TLSConfig(noCert)), listener.Addr()); err == nil {
+		t.Fatal("call without client certificate succeeded")
+	}
+}
+
+func peerSerial(t *testing.T, addr string, pool *x509.CertPool) string {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+}
+
+func TestCertificateReload(t *testing.T) {
+	ca, _ := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, ca, dir, "server")
+
+	cr, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("create reloader: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cr.watch(ctx, 10*time.Millisecond, newLoggerWrapper("test"))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: cr.getCertificate})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	pool := caPool(t, ca)
+	before := peerSerial(t, listener.Addr().String(), pool)
+
+	writeCert(t, ca, dir, "server")
+	// file systems with coarse timestamps may keep modification time of rewritten files
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatalf("touch %s: %v", f, err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for peerSerial(t, listener.Addr().String(), pool) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
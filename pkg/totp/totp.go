@@ -0,0 +1,136 @@
+// Package totp implements rfc 6238 one-time codes
+// gateway makes enrollment uri only, secrets and codes are generated and validated by auth service
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits amount of digits in generated code
+	Digits = 6
+	// Period code lifetime
+	Period = 30 * time.Second
+	// Skew amount of periods before and after current one accepted by Validate
+	Skew = 1
+
+	secretSize       = 20
+	recoveryCodeSize = 10
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret generates new base32 encoded secret
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+
+	return encoding.EncodeToString(buf), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	return encoding.DecodeString(secret)
+}
+
+func code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
+
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(Period/time.Second)
+}
+
+// GenerateCode generates code for specified time
+func GenerateCode(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+
+	return code(key, counter(t)), nil
+}
+
+// Validate checks code for specified time taking into account clock skew
+func Validate(secret string, passcode string, t time.Time) (bool, error) {
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != Digits {
+		return false, nil
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return false, fmt.Errorf("decode secret: %w", err)
+	}
+
+	c := counter(t)
+	for i := -Skew; i <= Skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(code(key, c+uint64(i))), []byte(passcode)) == 1 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// URI returns otpauth uri for authenticator applications
+func URI(issuer, account, secret string) string {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + account,
+	}
+
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprintf("%d", Digits))
+	q.Set("period", fmt.Sprintf("%d", int(Period/time.Second)))
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// GenerateRecoveryCodes generates one-time recovery codes
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	buf := make([]byte, recoveryCodeSize)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("read random: %w", err)
+		}
+
+		c := strings.ToLower(encoding.EncodeToString(buf))
+		codes = append(codes, c[:8]+"-"+c[8:16])
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,102 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret base32 encoded "12345678901234567890" key of rfc 6238 sha1 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc 6238 appendix b codes are 8 digits, generated ones are their last 6 digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestGenerateCode(t *testing.T) {
+	for _, v := range rfcVectors {
+		c, err := GenerateCode(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("generate code: %v", err)
+		}
+
+		if c != v.code {
+			t.Errorf("time %d: expected code %s, got %s", v.unix, v.code, c)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name  string
+		code  string
+		at    time.Time
+		valid bool
+	}{
+		{"current period", "050471", now, true},
+		{"spaces around code", " 050471 ", now, true},
+		{"previous period", "050471", now.Add(Period), true},
+		{"next period", "050471", now.Add(-Period), true},
+		{"out of skew", "050471", now.Add(2 * Period), false},
+		{"wrong code", "050472", now, false},
+		{"full rfc code", "14050471", now, false},
+	}
+
+	for _, tc := range tests {
+		valid, err := Validate(rfcSecret, tc.code, tc.at)
+		if err != nil {
+			t.Fatalf("%s: validate: %v", tc.name, err)
+		}
+
+		if valid != tc.valid {
+			t.Errorf("%s: expected valid %t, got %t", tc.name, tc.valid, valid)
+		}
+	}
+
+	if _, err := Validate("not base32!", "050471", now); err == nil {
+		t.Error("invalid secret is accepted")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	if len(key) != secretSize {
+		t.Errorf("expected %d bytes key, got %d", secretSize, len(key))
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Duplo", "alice", rfcSecret))
+	if err != nil {
+		t.Fatalf("parse uri: %v", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Duplo:alice" {
+		t.Errorf("unexpected uri: %s", u)
+	}
+
+	q := u.Query()
+	if q.Get("secret") != rfcSecret || q.Get("issuer") != "Duplo" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("unexpected uri parameters: %s", u.RawQuery)
+	}
+}